@@ -0,0 +1,95 @@
+// Package catalog references the chall-manager recipes by name, such that
+// their configurations could be decoded and previewed without deploying
+// anything (e.g. by the debug recipe).
+package catalog
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/ctfer-io/recipes"
+	e1p "github.com/ctfer-io/recipes/chall-manager/k8s.E1P/recipe"
+	emp "github.com/ctfer-io/recipes/chall-manager/k8s.EMP/recipe"
+	"github.com/ctfer-io/recipes/dryrun"
+)
+
+// Result is the outcome of a recipe preview.
+type Result = dryrun.Result
+
+// Recipe is a recipe, regardless of its configuration type.
+type Recipe interface {
+	// Name of the recipe, i.e. its directory (e.g. `k8s.E1P`).
+	Name() string
+
+	// Decode the additional values into the recipe configuration, as
+	// [recipes.Run] does before calling the recipe factory.
+	Decode(additionals map[string]string) (any, error)
+
+	// Preview runs the recipe against Pulumi mocks (see [dryrun.Run]),
+	// with the given identity and additional values.
+	Preview(identity string, additionals map[string]string) (*Result, error)
+
+	// Type of the recipe configuration.
+	Type() reflect.Type
+
+	// Schema of the recipe configuration (see [recipes.SchemaOf]).
+	Schema(docs recipes.Docs) *recipes.Schema
+}
+
+// New references a recipe factory under its name.
+func New[T any](name string, f recipes.Factory[T]) Recipe {
+	return &recipe[T]{
+		name: name,
+		f:    f,
+	}
+}
+
+type recipe[T any] struct {
+	name string
+	f    recipes.Factory[T]
+}
+
+func (r *recipe[T]) Name() string {
+	return r.name
+}
+
+func (r *recipe[T]) Decode(additionals map[string]string) (any, error) {
+	return recipes.Decode[T](additionals)
+}
+
+func (r *recipe[T]) Preview(identity string, additionals map[string]string) (*Result, error) {
+	return dryrun.Run(r.f, identity, additionals)
+}
+
+func (r *recipe[T]) Type() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+func (r *recipe[T]) Schema(docs recipes.Docs) *recipes.Schema {
+	return recipes.SchemaOf[T](docs)
+}
+
+var catalog = []Recipe{
+	New("k8s.E1P", e1p.Factory),
+	New("k8s.EMP", emp.Factory),
+}
+
+// Get returns the recipe of the given name, if it exists.
+func Get(name string) (Recipe, bool) {
+	for _, r := range catalog {
+		if r.Name() == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// Names returns the names of the recipes, sorted.
+func Names() []string {
+	names := make([]string, 0, len(catalog))
+	for _, r := range catalog {
+		names = append(names, r.Name())
+	}
+	slices.Sort(names)
+	return names
+}
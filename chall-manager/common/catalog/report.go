@@ -0,0 +1,164 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/ctfer-io/recipes"
+)
+
+// Report describes what a recipe would deploy given its additional values.
+// Secrets are redacted.
+type Report struct {
+	// Recipe name.
+	Recipe string `json:"recipe"`
+
+	// Configuration decoded, defaulted and validated.
+	Configuration any `json:"configuration,omitempty"`
+
+	// ConnectionInfo rendered with placeholder URLs.
+	ConnectionInfo string `json:"connectionInfo,omitempty"`
+
+	// Flags produced for the instance.
+	Flags []string `json:"flags,omitempty"`
+
+	// Resources that would be deployed.
+	Resources []ReportResource `json:"resources,omitempty"`
+
+	// Errors in the configuration, if any, in which case nothing would be
+	// deployed.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ReportResource is a resource that would be deployed.
+type ReportResource struct {
+	// Type token of the resource.
+	Type string `json:"type"`
+
+	// Name of the resource, as given to Pulumi.
+	Name string `json:"name"`
+
+	// Inputs of the resource.
+	Inputs map[string]any `json:"inputs"`
+}
+
+// Preview the recipe with the identity and additional values, and report
+// what it would deploy.
+// The URLs are placeholders, as provided by the Pulumi mocks (e.g. node
+// ports are derived from the ports).
+// Errors in the configuration are part of the report.
+func Preview(r Recipe, identity string, additionals map[string]string) *Report {
+	rep := &Report{
+		Recipe: r.Name(),
+	}
+
+	conf, err := r.Decode(additionals)
+	if err != nil {
+		rep.Errors = errorStrings(err)
+		return rep
+	}
+	redacted := recipes.Redact(conf)
+	rep.Configuration = redacted
+
+	res, err := r.Preview(identity, additionals)
+	if err != nil {
+		rep.Errors = errorStrings(err)
+		return rep
+	}
+	rep.ConnectionInfo = res.ConnectionInfo
+	rep.Flags = res.Flags
+	if secretFlags(redacted) {
+		for i := range rep.Flags {
+			rep.Flags[i] = recipes.Redacted
+		}
+	}
+	for _, r := range res.Resources {
+		rep.Resources = append(rep.Resources, ReportResource{
+			Type:   r.Type,
+			Name:   r.Name,
+			Inputs: r.Redacted(),
+		})
+	}
+	return rep
+}
+
+// JSON returns the report as indented JSON.
+func (rep *Report) JSON() (string, error) {
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Text returns the report in a human-readable form.
+func (rep *Report) Text() (string, error) {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Recipe: %s\n", rep.Recipe)
+	if len(rep.Errors) != 0 {
+		b.WriteString("Errors:\n")
+		for _, err := range rep.Errors {
+			fmt.Fprintf(b, "- %s\n", err)
+		}
+		return b.String(), nil
+	}
+
+	conf, err := json.MarshalIndent(rep.Configuration, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(b, "Configuration:\n%s\n", conf)
+	fmt.Fprintf(b, "Connection info:\n%s\n", rep.ConnectionInfo)
+	if len(rep.Flags) != 0 {
+		b.WriteString("Flags:\n")
+		for _, flag := range rep.Flags {
+			fmt.Fprintf(b, "- %s\n", flag)
+		}
+	}
+	b.WriteString("Resources:\n")
+	for _, r := range rep.Resources {
+		fmt.Fprintf(b, "- %s %s\n", r.Type, r.Name)
+	}
+	return b.String(), nil
+}
+
+// secretFlags returns whether the flag or any of the flags of the redacted
+// configuration is a secret.
+func secretFlags(redacted any) bool {
+	conf, ok := redacted.(map[string]any)
+	if !ok {
+		return false
+	}
+	if conf["flag"] == recipes.Redacted {
+		return true
+	}
+	flags, _ := conf["flags"].([]any)
+	for _, flag := range flags {
+		if flag == recipes.Redacted {
+			return true
+		}
+	}
+	return false
+}
+
+// errorStrings lists the unsatisfied constraints of a validation error, or
+// the recipe error otherwise (rather than the Pulumi one wrapping it).
+func errorStrings(err error) []string {
+	var verr *recipes.ValidationError
+	if errors.As(err, &verr) {
+		errs := []string{}
+		for _, err := range multierr.Errors(verr.Err) {
+			errs = append(errs, err.Error())
+		}
+		return errs
+	}
+	var rerr recipes.Error
+	if errors.As(err, &rerr) {
+		return []string{rerr.Error()}
+	}
+	return []string{err.Error()}
+}
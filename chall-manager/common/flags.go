@@ -0,0 +1,36 @@
+package common
+
+import "slices"
+
+// FlagsArgs defines the flags of an instance, that could be variated per
+// identity such that each instance has its own.
+// Embed it in a recipe configuration to support flags.
+type FlagsArgs struct {
+	// The flag of the instance, variated per its identity if
+	// `flag.variate=true`.
+	Flag Variable `form:"flag" json:"flag,omitempty"`
+
+	// Additional flags of the instance.
+	Flags []Variable `form:"flags" json:"flags,omitempty"`
+}
+
+// ProduceFlags returns the flags given their configuration and a seed
+// (should be the instance identity for proper reproducibility).
+// The flag, if defined, comes first.
+func (args FlagsArgs) ProduceFlags(seed string) []string {
+	out := make([]string, 0, len(args.Flags)+1)
+	if args.Flag.Content != "" {
+		out = append(out, args.Flag.Produce(seed))
+	}
+	for _, flag := range args.Flags {
+		out = append(out, flag.Produce(seed))
+	}
+	return out
+}
+
+// SecretFlags returns whether any flag is secret.
+func (args FlagsArgs) SecretFlags() bool {
+	return args.Flag.Secret || slices.ContainsFunc(args.Flags, func(flag Variable) bool {
+		return flag.Secret
+	})
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"text/template"
+)
+
+// DefaultFallback is the text helpers return when the URL they are given
+// is missing or malformed (e.g. the port is not exposed yet).
+const DefaultFallback = "unavailable"
+
+// helpers returns the functions registered on top of sprig to write
+// connection info for common protocols.
+// They all accept URLs as exposed by the recipes, i.e. `host:port` or `host`
+// (e.g. for an Ingress), and return the fallback if it is missing.
+func helpers(fallback string) template.FuncMap {
+	return template.FuncMap{
+		"host": func(url string) string {
+			host, _, ok := splitURL(url)
+			if !ok {
+				return fallback
+			}
+			return host
+		},
+		"port": func(url string) string {
+			_, port, ok := splitURL(url)
+			if !ok || port == "" {
+				return fallback
+			}
+			return port
+		},
+		"sshCmd": func(url string, user ...string) string {
+			host, port, ok := splitURL(url)
+			if !ok || port == "" {
+				return fallback
+			}
+			if len(user) != 0 && user[0] != "" {
+				host = user[0] + "@" + host
+			}
+			return fmt.Sprintf("ssh -p %s %s", port, host)
+		},
+		"ncCmd": func(url string) string {
+			host, port, ok := splitURL(url)
+			if !ok || port == "" {
+				return fallback
+			}
+			return fmt.Sprintf("nc %s %s", host, port)
+		},
+		"httpURL": func(url string) string {
+			if _, _, ok := splitURL(url); !ok {
+				return fallback
+			}
+			return "http://" + url
+		},
+		"httpsURL": func(url string) string {
+			if _, _, ok := splitURL(url); !ok {
+				return fallback
+			}
+			return "https://" + url
+		},
+		"openSSLCmd": func(url string) string {
+			host, port, ok := splitURL(url)
+			if !ok {
+				return fallback
+			}
+			if port == "" {
+				port = "443"
+			}
+			return fmt.Sprintf("openssl s_client -connect %s -servername %s", net.JoinHostPort(host, port), host)
+		},
+	}
+}
+
+// splitURL splits a `host:port` or `host` URL, and returns whether it is
+// well-formed.
+func splitURL(url string) (host, port string, ok bool) {
+	url = strings.TrimSpace(url)
+	if url == "" {
+		return "", "", false
+	}
+	if !strings.Contains(url, ":") {
+		return url, "", true
+	}
+	host, port, err := net.SplitHostPort(url)
+	if err != nil || host == "" || port == "" {
+		return "", "", false
+	}
+	return host, port, true
+}
@@ -0,0 +1,147 @@
+package common
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"maps"
+	"slices"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"github.com/ctfer-io/recipes"
+)
+
+// InitContainerArgs defines a container that runs to completion before the
+// challenge container starts (e.g. to seed a database with the flag), or
+// alongside it as a sidecar.
+type InitContainerArgs struct {
+	// The Docker image reference to run.
+	Image string `form:"image" json:"image" validate:"required" override:"locked"`
+
+	// Whether the container is a sidecar, i.e. it starts before the
+	// challenge container and keeps running alongside it (e.g. a proxy or
+	// a log shipper) rather than to completion.
+	// Requires Kubernetes 1.29 or later (native sidecars).
+	Sidecar bool `form:"sidecar" json:"sidecar,omitempty"`
+
+	// How the container runs its image (e.g. its command).
+	RuntimeArgs
+
+	// The environment variables to pass to the container.
+	// Their contents could be Go templates rendered with the instance values.
+	Envs map[string]Variable `form:"envs" json:"envs,omitempty"`
+
+	// The files to mount in the container, identified by their absolute path.
+	// Their contents could be Go templates rendered with the instance values.
+	Files map[string]Variable `form:"files" json:"files,omitempty" validate:"omitempty,dive,keys,startswith=/,endkeys"`
+
+	// The resource requests of the container.
+	Requests map[string]string `form:"requests" json:"requests,omitempty" default:"cpu=100m,memory=128Mi" override:"locked"`
+
+	// The resource limits of the container.
+	Limits map[string]string `form:"limits" json:"limits,omitempty" default:"cpu=500m,memory=256Mi" override:"locked"`
+}
+
+// InitContainers renders the init containers envs and files with the
+// instance values (see [Variable.Render]), reporting template errors under
+// path (e.g. `initContainers`).
+// Their files are set in a ConfigMap, named after name, the stack and the
+// seed (i.e. the instance identity).
+// The returned patch adds them to the pod, to run in order, as `init-<i>`
+// (sidecars included).
+func InitContainers(ctx *pulumi.Context, name, path string, inits []InitContainerArgs, seed string, values *Values, opts ...pulumi.ResourceOption) (PodPatch, error) {
+	if len(inits) == 0 {
+		return func(*corev1.PodSpecArgs, *corev1.ContainerArgs) {}, nil
+	}
+
+	// Volumes are identified the same way the SDK does for files, to avoid
+	// conflicts with the ones of challenge authors
+	volume := fmt.Sprintf("%x", sha1.Sum([]byte(name)))
+
+	ctrs := make([]corev1.ContainerArgs, 0, len(inits))
+	data := pulumi.StringMap{}
+	for i, init := range inits {
+		envs := corev1.EnvVarArray{}
+		for _, k := range slices.Sorted(maps.Keys(init.Envs)) {
+			v := init.Envs[k]
+			content, err := v.Render(seed, values)
+			if err != nil {
+				return nil, &recipes.TemplateError{Path: fmt.Sprintf("%s[%d].envs[%s]", path, i, k), Err: err}
+			}
+			envs = append(envs, corev1.EnvVarArgs{
+				Name:  pulumi.String(k),
+				Value: v.ToInput(content, values).ToStringOutput().ToStringPtrOutput(),
+			})
+		}
+		mounts := corev1.VolumeMountArray{}
+		for j, dst := range slices.Sorted(maps.Keys(init.Files)) {
+			f := init.Files[dst]
+			content, err := f.Render(seed, values)
+			if err != nil {
+				return nil, &recipes.TemplateError{Path: fmt.Sprintf("%s[%d].files[%s]", path, i, dst), Err: err}
+			}
+			key := fmt.Sprintf("init-%d-%d", i, j)
+			data[key] = f.ToInput(content, values)
+			mounts = append(mounts, corev1.VolumeMountArgs{
+				Name:      pulumi.String(volume),
+				MountPath: pulumi.String(dst),
+				SubPath:   pulumi.String(key),
+				ReadOnly:  pulumi.Bool(true),
+			})
+		}
+
+		ctr := corev1.ContainerArgs{
+			Name:         pulumi.String(fmt.Sprintf("init-%d", i)),
+			Image:        pulumi.String(init.Image),
+			Env:          envs,
+			VolumeMounts: mounts,
+			Resources: corev1.ResourceRequirementsArgs{
+				Requests: pulumi.ToStringMap(init.Requests),
+				Limits:   pulumi.ToStringMap(init.Limits),
+			},
+		}
+		if init.Sidecar {
+			// Native sidecar: restarted, and kept running with the pod
+			ctr.RestartPolicy = pulumi.StringPtr("Always")
+		}
+		init.RuntimeArgs.Patch(nil, &ctr)
+		ctrs = append(ctrs, ctr)
+	}
+
+	var files corev1.VolumeInput
+	if len(data) != 0 {
+		cfg, err := corev1.NewConfigMap(ctx, name, &corev1.ConfigMapArgs{
+			Immutable: pulumi.BoolPtr(true),
+			Metadata: metav1.ObjectMetaArgs{
+				Name: pulumi.Sprintf("%s-%s-%s", name, ctx.Stack(), seed),
+				Labels: pulumi.StringMap{
+					"chall-manager.ctfer.io/identity": pulumi.String(seed),
+				},
+			},
+			Data: data,
+		}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		files = corev1.VolumeArgs{
+			Name: pulumi.String(volume),
+			ConfigMap: corev1.ConfigMapVolumeSourceArgs{
+				Name:        cfg.Metadata.Name(),
+				DefaultMode: pulumi.Int(0444), // -r--r--r--
+			},
+		}
+	}
+
+	return func(pod *corev1.PodSpecArgs, _ *corev1.ContainerArgs) {
+		cur, _ := pod.InitContainers.(corev1.ContainerArray)
+		for _, ctr := range ctrs {
+			cur = append(cur, ctr)
+		}
+		pod.InitContainers = cur
+		if files != nil {
+			AppendVolumes(pod, files)
+		}
+	}, nil
+}
@@ -0,0 +1,173 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// MonopodContainer is the name the chall-manager SDK gives to the container
+// of a [k8s.ExposedMonopod].
+//
+// [k8s.ExposedMonopod]: https://pkg.go.dev/github.com/ctfer-io/chall-manager/sdk/kubernetes#ExposedMonopod
+const MonopodContainer = "one"
+
+// SourceLabel is the label set on the patched pods to identify their
+// source, i.e. their image, as the chall-manager SDK labels only identify
+// the instance and the container name (e.g. [MonopodContainer]) is shared
+// across challenges.
+// Its value is a hash of the image, such that it is a valid label value.
+const SourceLabel = "recipes.ctfer.io/source"
+
+// PodPatch patches a pod spec and its container, to set what the
+// chall-manager SDK does not expose (e.g. the container command).
+type PodPatch func(pod *corev1.PodSpecArgs, ctr *corev1.ContainerArgs)
+
+// PatchPods returns a resource option that applies the patches to the pods
+// of the Deployments the chall-manager SDK creates, identified by their
+// container name (e.g. [MonopodContainer]).
+// It is meant to be passed to the SDK components, which pass it down to
+// their children.
+func PatchPods(patches map[string]PodPatch) pulumi.ResourceOption {
+	mx := sync.Mutex{}
+	patched := map[string]bool{}
+
+	return pulumi.Transformations([]pulumi.ResourceTransformation{
+		func(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+			name, ok := strings.CutPrefix(args.Name, "emp-dep-")
+			if !ok || args.Type != "kubernetes:apps/v1:Deployment" {
+				return nil
+			}
+			patch, ok := patches[name]
+			if !ok {
+				return nil
+			}
+
+			// Transformations are inherited from the parents, so run once
+			// per resource
+			mx.Lock()
+			defer mx.Unlock()
+			if patched[name] {
+				return nil
+			}
+			patched[name] = true
+
+			// Fail loudly rather than silently ignoring the configuration,
+			// if the SDK changes how it builds its Deployments (the Recover
+			// middleware turns it into a provisioning error)
+			dep, ok := args.Props.(*appsv1.DeploymentArgs)
+			if !ok {
+				panic("unexpected Deployment arguments from the chall-manager SDK")
+			}
+			tmpl, pod, ctrs := podSpec(dep)
+			ctr := ctrs[0].(corev1.ContainerArgs)
+			patch(pod, &ctr)
+			ctrs[0] = ctr
+			labelSource(tmpl, ctr.Image)
+
+			return &pulumi.ResourceTransformationResult{
+				Props: dep,
+				Opts:  args.Opts,
+			}
+		},
+	})
+}
+
+// AppendVolumes appends volumes to a pod spec, whether the SDK set them as
+// an output (e.g. for files) or not.
+func AppendVolumes(pod *corev1.PodSpecArgs, vs ...corev1.VolumeInput) {
+	extra := corev1.VolumeArray(vs)
+	switch cur := pod.Volumes.(type) {
+	case corev1.VolumeArrayOutput:
+		if cur.OutputState != nil {
+			pod.Volumes = pulumi.All(cur, extra).ApplyT(func(all []any) []corev1.Volume {
+				return append(all[0].([]corev1.Volume), all[1].([]corev1.Volume)...)
+			}).(corev1.VolumeArrayOutput)
+			return
+		}
+	case corev1.VolumeArray:
+		pod.Volumes = append(cur, extra...)
+		return
+	}
+	pod.Volumes = extra
+}
+
+// AppendVolumeMounts appends volume mounts to a container, whether the SDK
+// set them as an output (e.g. for files) or not.
+func AppendVolumeMounts(ctr *corev1.ContainerArgs, vms ...corev1.VolumeMountInput) {
+	extra := corev1.VolumeMountArray(vms)
+	switch cur := ctr.VolumeMounts.(type) {
+	case corev1.VolumeMountArrayOutput:
+		if cur.OutputState != nil {
+			ctr.VolumeMounts = pulumi.All(cur, extra).ApplyT(func(all []any) []corev1.VolumeMount {
+				return append(all[0].([]corev1.VolumeMount), all[1].([]corev1.VolumeMount)...)
+			}).(corev1.VolumeMountArrayOutput)
+			return
+		}
+	case corev1.VolumeMountArray:
+		ctr.VolumeMounts = append(cur, extra...)
+		return
+	}
+	ctr.VolumeMounts = extra
+}
+
+// labelSource sets the [SourceLabel] on the pod template, without altering
+// the labels the SDK shares with other resources (e.g. the selector).
+func labelSource(tmpl *corev1.PodTemplateSpecArgs, image pulumi.StringPtrInput) {
+	meta, ok := tmpl.Metadata.(*metav1.ObjectMetaArgs)
+	if !ok {
+		panic("unexpected Deployment pod metadata from the chall-manager SDK")
+	}
+	labels := pulumi.StringMap{}
+	if cur, ok := meta.Labels.(pulumi.StringMap); ok {
+		for k, v := range cur {
+			labels[k] = v
+		}
+	}
+	labels[SourceLabel] = source(image)
+	patched := *meta
+	patched.Labels = labels
+	tmpl.Metadata = &patched
+}
+
+// source returns the [SourceLabel] value of the pods running the image.
+func source(image pulumi.StringPtrInput) pulumi.StringOutput {
+	return image.ToStringPtrOutput().ApplyT(func(image *string) string {
+		ref := ""
+		if image != nil {
+			ref = *image
+		}
+		sum := sha256.Sum256([]byte(ref))
+		return hex.EncodeToString(sum[:16])
+	}).(pulumi.StringOutput)
+}
+
+func podSpec(dep *appsv1.DeploymentArgs) (*corev1.PodTemplateSpecArgs, *corev1.PodSpecArgs, corev1.ContainerArray) {
+	if spec, ok := dep.Spec.(appsv1.DeploymentSpecArgs); ok {
+		if tmpl, ok := spec.Template.(*corev1.PodTemplateSpecArgs); ok {
+			if pod, ok := tmpl.Spec.(*corev1.PodSpecArgs); ok {
+				if ctrs, ok := pod.Containers.(corev1.ContainerArray); ok && len(ctrs) == 1 {
+					if _, ok := ctrs[0].(corev1.ContainerArgs); ok {
+						return tmpl, pod, ctrs
+					}
+				}
+			}
+		}
+	}
+	panic("unexpected Deployment pod spec from the chall-manager SDK")
+}
+
+// Patches combines patches, applied in order.
+func Patches(patches ...PodPatch) PodPatch {
+	return func(pod *corev1.PodSpecArgs, ctr *corev1.ContainerArgs) {
+		for _, patch := range patches {
+			patch(pod, ctr)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// PlacementArgs defines on which nodes the pods are scheduled (e.g. the
+// nodes dedicated to heavy challenges).
+type PlacementArgs struct {
+	// The labels the nodes must have to run the pods (e.g.
+	// `nodeSelector[pool]=pwn`).
+	NodeSelector map[string]string `form:"nodeSelector" json:"nodeSelector,omitempty" override:"locked"`
+
+	// The taints of the nodes the pods tolerate (e.g. the ones of dedicated
+	// nodes).
+	Tolerations []TolerationArgs `form:"tolerations" json:"tolerations,omitempty" validate:"dive" override:"locked"`
+
+	// Whether to spread the instances across nodes, by preferring the ones
+	// that do not run the same container (i.e. with the same image) yet
+	// (best effort).
+	Spread bool `form:"spread" json:"spread,omitempty"`
+}
+
+// TolerationArgs tolerates a node taint.
+type TolerationArgs struct {
+	// The taint key to tolerate. Empty with `operator=Exists` to tolerate
+	// all taints.
+	Key string `form:"key" json:"key,omitempty"`
+
+	// How the taint value is matched: `Equal` to the value, or `Exists` for
+	// any.
+	Operator string `form:"operator" json:"operator,omitempty" validate:"omitempty,oneof=Equal Exists" default:"Equal"`
+
+	// The taint value to tolerate, with `operator=Equal`.
+	Value string `form:"value" json:"value,omitempty" validate:"excluded_if=Operator Exists"`
+
+	// The taint effect to tolerate: `NoSchedule`, `PreferNoSchedule` or
+	// `NoExecute`. Empty to tolerate all effects.
+	Effect string `form:"effect" json:"effect,omitempty" validate:"omitempty,oneof=NoSchedule PreferNoSchedule NoExecute"`
+
+	// How long (in seconds) the pods stay on a node once tainted, with
+	// `effect=NoExecute`. Forever if not set.
+	TolerationSeconds *int `form:"tolerationSeconds" json:"tolerationSeconds,omitempty" validate:"omitempty,min=0"`
+}
+
+// spreadTopologyKey is the node label instances are spread across.
+const spreadTopologyKey = "kubernetes.io/hostname"
+
+// Patch returns a [PodPatch] that places the pod of the container, named
+// as in the chall-manager SDK (e.g. [MonopodContainer]).
+func (args PlacementArgs) Patch(container string) PodPatch {
+	return func(pod *corev1.PodSpecArgs, ctr *corev1.ContainerArgs) {
+		if len(args.NodeSelector) != 0 {
+			pod.NodeSelector = pulumi.ToStringMap(args.NodeSelector)
+		}
+		if len(args.Tolerations) != 0 {
+			tols := make(corev1.TolerationArray, 0, len(args.Tolerations))
+			for _, tol := range args.Tolerations {
+				tols = append(tols, corev1.TolerationArgs{
+					Key:               pulumi.StringPtr(tol.Key),
+					Operator:          pulumi.StringPtr(tol.Operator),
+					Value:             pulumi.StringPtr(tol.Value),
+					Effect:            pulumi.StringPtr(tol.Effect),
+					TolerationSeconds: pulumi.IntPtrFromPtr(tol.TolerationSeconds),
+				})
+			}
+			pod.Tolerations = tols
+		}
+		if args.Spread {
+			// Select the pods of the same container, whatever the instance,
+			// with the labels the SDK sets and the source one, as container
+			// names are shared across challenges
+			pod.Affinity = corev1.AffinityArgs{
+				PodAntiAffinity: corev1.PodAntiAffinityArgs{
+					PreferredDuringSchedulingIgnoredDuringExecution: corev1.WeightedPodAffinityTermArray{
+						corev1.WeightedPodAffinityTermArgs{
+							Weight: pulumi.Int(100),
+							PodAffinityTerm: corev1.PodAffinityTermArgs{
+								TopologyKey: pulumi.String(spreadTopologyKey),
+								LabelSelector: metav1.LabelSelectorArgs{
+									MatchLabels: pulumi.StringMap{
+										"chall-manager.ctfer.io/kind": pulumi.String("exposed-multipod"),
+										"app.kubernetes.io/name":      pulumi.String(container),
+										SourceLabel:                   source(ctr.Image),
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+		}
+	}
+}
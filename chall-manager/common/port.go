@@ -4,9 +4,20 @@ import (
 	k8s "github.com/ctfer-io/chall-manager/sdk/kubernetes"
 )
 
+// PortArgs defines a port of a container, and how to expose it.
 type PortArgs struct {
-	Port        int               `form:"port"        json:"port"`
-	Protocol    string            `form:"protocol"    json:"protocol"`
-	ExposeType  k8s.ExposeType    `form:"exposeType"  json:"exposeType"`
+	// The port the container listens on.
+	Port int `form:"port" json:"port" validate:"required,min=1,max=65535"`
+
+	// The protocol to expose the port on.
+	Protocol string `form:"protocol" json:"protocol" validate:"omitempty,oneof=TCP UDP SCTP" default:"TCP"`
+
+	// The kind of exposure for this port/protocol couple, `NodePort`,
+	// `Ingress` or `LoadBalancer`. Only reachable from within the cluster
+	// if unset.
+	ExposeType k8s.ExposeType `form:"exposeType" json:"exposeType" validate:"omitempty,oneof=NodePort Ingress LoadBalancer"`
+
+	// The annotations to pass to the exposing resource of this port/protocol
+	// couple.
 	Annotations map[string]string `form:"annotations" json:"annotations,omitempty"`
 }
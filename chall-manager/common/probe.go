@@ -0,0 +1,183 @@
+package common
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/multierr"
+
+	"github.com/ctfer-io/recipes"
+)
+
+// ProbesArgs defines the probes Kubernetes runs to check the health of a
+// container.
+type ProbesArgs struct {
+	// Restarts the container when it fails.
+	Liveness *ProbeArgs `form:"livenessProbe" json:"livenessProbe,omitempty"`
+
+	// Holds the traffic until it succeeds, such that the instance is only
+	// reported as ready once the service inside is.
+	Readiness *ProbeArgs `form:"readinessProbe" json:"readinessProbe,omitempty"`
+
+	// Holds the other probes until it succeeds, for slow-booting services.
+	Startup *ProbeArgs `form:"startupProbe" json:"startupProbe,omitempty"`
+}
+
+// ProbeArgs defines a probe, with exactly one handler.
+type ProbeArgs struct {
+	// Performs an HTTP GET request against the container, healthy if the
+	// response status code is in [200, 400).
+	HTTPGet *HTTPGetArgs `form:"httpGet" json:"httpGet,omitempty"`
+
+	// Opens a TCP connection to the container, healthy if it succeeds.
+	TCPSocket *TCPSocketArgs `form:"tcpSocket" json:"tcpSocket,omitempty"`
+
+	// Runs a command in the container, healthy if it exits with 0.
+	Exec *ExecArgs `form:"exec" json:"exec,omitempty" override:"locked"`
+
+	// The number of seconds after the container has started before probing.
+	InitialDelaySeconds int `form:"initialDelaySeconds" json:"initialDelaySeconds,omitempty" validate:"omitempty,min=0"`
+
+	// How often (in seconds) to probe. Kubernetes defaults it to 10.
+	PeriodSeconds int `form:"periodSeconds" json:"periodSeconds,omitempty" validate:"omitempty,min=1"`
+
+	// The number of seconds after which the probe times out. Kubernetes
+	// defaults it to 1.
+	TimeoutSeconds int `form:"timeoutSeconds" json:"timeoutSeconds,omitempty" validate:"omitempty,min=1"`
+
+	// The number of consecutive successes for the probe to be considered
+	// successful after having failed. Must be 1 for liveness and startup
+	// probes, which Kubernetes defaults it to.
+	SuccessThreshold int `form:"successThreshold" json:"successThreshold,omitempty" validate:"omitempty,min=1"`
+
+	// The number of consecutive failures for the probe to be considered
+	// failed. Kubernetes defaults it to 3.
+	FailureThreshold int `form:"failureThreshold" json:"failureThreshold,omitempty" validate:"omitempty,min=1"`
+}
+
+// HTTPGetArgs defines an HTTP GET request probe handler.
+type HTTPGetArgs struct {
+	// The path to request.
+	Path string `form:"path" json:"path,omitempty" default:"/"`
+
+	// The port to request, on the container.
+	Port int `form:"port" json:"port" validate:"required,min=1,max=65535"`
+
+	// The scheme to request with.
+	Scheme string `form:"scheme" json:"scheme,omitempty" validate:"omitempty,oneof=HTTP HTTPS" default:"HTTP"`
+
+	// The headers to set on the request.
+	Headers map[string]string `form:"headers" json:"headers,omitempty"`
+}
+
+// TCPSocketArgs defines a TCP connection probe handler.
+type TCPSocketArgs struct {
+	// The port to connect to, on the container.
+	Port int `form:"port" json:"port" validate:"required,min=1,max=65535"`
+}
+
+// ExecArgs defines a command probe handler.
+type ExecArgs struct {
+	// The command to run, with its arguments. It is not run in a shell.
+	Command []string `form:"command" json:"command" validate:"required,min=1"`
+}
+
+// Check returns a [recipes.FieldError] for each probe that could not be
+// run, with its path prefixed by path (e.g. `containers[app]`), if any.
+func (args ProbesArgs) Check(path string) (merr error) {
+	for _, p := range []struct {
+		name  string
+		probe *ProbeArgs
+	}{
+		{"livenessProbe", args.Liveness},
+		{"readinessProbe", args.Readiness},
+		{"startupProbe", args.Startup},
+	} {
+		if p.probe == nil {
+			continue
+		}
+		name := p.name
+		if path != "" {
+			name = path + "." + name
+		}
+
+		handlers := 0
+		for _, set := range []bool{p.probe.HTTPGet != nil, p.probe.TCPSocket != nil, p.probe.Exec != nil} {
+			if set {
+				handlers++
+			}
+		}
+		if handlers != 1 {
+			merr = multierr.Append(merr, &recipes.FieldError{
+				Path: name,
+				Rule: "exactly one of httpGet, tcpSocket or exec",
+			})
+		}
+		if p.name != "readinessProbe" && p.probe.SuccessThreshold > 1 {
+			merr = multierr.Append(merr, &recipes.FieldError{
+				Path: name + ".successThreshold",
+				Rule: fmt.Sprintf("must be 1 for a %s", p.name),
+			})
+		}
+	}
+	return
+}
+
+// Patch sets the probes on the container, as a [PodPatch].
+func (args ProbesArgs) Patch(_ *corev1.PodSpecArgs, ctr *corev1.ContainerArgs) {
+	if args.Liveness != nil {
+		ctr.LivenessProbe = args.Liveness.toProbe()
+	}
+	if args.Readiness != nil {
+		ctr.ReadinessProbe = args.Readiness.toProbe()
+	}
+	if args.Startup != nil {
+		ctr.StartupProbe = args.Startup.toProbe()
+	}
+}
+
+func (args ProbeArgs) toProbe() *corev1.ProbeArgs {
+	probe := &corev1.ProbeArgs{
+		InitialDelaySeconds: intPtr(args.InitialDelaySeconds),
+		PeriodSeconds:       intPtr(args.PeriodSeconds),
+		TimeoutSeconds:      intPtr(args.TimeoutSeconds),
+		SuccessThreshold:    intPtr(args.SuccessThreshold),
+		FailureThreshold:    intPtr(args.FailureThreshold),
+	}
+	switch {
+	case args.HTTPGet != nil:
+		headers := corev1.HTTPHeaderArray{}
+		for _, k := range slices.Sorted(maps.Keys(args.HTTPGet.Headers)) {
+			headers = append(headers, corev1.HTTPHeaderArgs{
+				Name:  pulumi.String(k),
+				Value: pulumi.String(args.HTTPGet.Headers[k]),
+			})
+		}
+		probe.HttpGet = &corev1.HTTPGetActionArgs{
+			Path:        pulumi.String(args.HTTPGet.Path),
+			Port:        pulumi.Int(args.HTTPGet.Port),
+			Scheme:      pulumi.String(args.HTTPGet.Scheme),
+			HttpHeaders: headers,
+		}
+	case args.TCPSocket != nil:
+		probe.TcpSocket = &corev1.TCPSocketActionArgs{
+			Port: pulumi.Int(args.TCPSocket.Port),
+		}
+	case args.Exec != nil:
+		probe.Exec = &corev1.ExecActionArgs{
+			Command: pulumi.ToStringArray(args.Exec.Command),
+		}
+	}
+	return probe
+}
+
+// intPtr leaves zero values unset, for Kubernetes to default them.
+func intPtr(v int) pulumi.IntPtrInput {
+	if v == 0 {
+		return nil
+	}
+	return pulumi.IntPtr(v)
+}
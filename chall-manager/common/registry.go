@@ -0,0 +1,85 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// RegistryArgs defines how to pull the images from private registries.
+type RegistryArgs struct {
+	// The names of existing Secrets, in the namespace the instances are
+	// deployed in, to pull the images with.
+	ImagePullSecrets []string `form:"imagePullSecrets" json:"imagePullSecrets,omitempty" validate:"dive,required" override:"locked"`
+
+	// The credentials of a private registry, from which a Secret is created
+	// per instance to pull the images with.
+	Registry *RegistryCredentialsArgs `form:"registry" json:"registry,omitempty" override:"locked"`
+}
+
+// RegistryCredentialsArgs defines the credentials of a private registry.
+type RegistryCredentialsArgs struct {
+	// The registry server (e.g. `registry.ctfer.io`).
+	Server string `form:"server" json:"server" validate:"required"`
+
+	// The username to authenticate with.
+	Username string `form:"username" json:"username" validate:"required"`
+
+	// The password, or token, to authenticate with.
+	Password string `form:"password" json:"password" validate:"required" secret:"true"`
+}
+
+// ImagePullSecrets creates the docker-config Secret of the registry
+// credentials if any, named after name, the stack and the seed (i.e. the
+// instance identity).
+// The returned patch sets it on the pod, with the existing Secrets.
+func ImagePullSecrets(ctx *pulumi.Context, name string, args RegistryArgs, seed string, opts ...pulumi.ResourceOption) (PodPatch, error) {
+	refs := corev1.LocalObjectReferenceArray{}
+	for _, secret := range args.ImagePullSecrets {
+		refs = append(refs, corev1.LocalObjectReferenceArgs{
+			Name: pulumi.String(secret),
+		})
+	}
+
+	if creds := args.Registry; creds != nil {
+		cfg, err := json.Marshal(map[string]any{
+			"auths": map[string]any{
+				creds.Server: map[string]string{
+					"username": creds.Username,
+					"password": creds.Password,
+					"auth":     base64.StdEncoding.EncodeToString([]byte(creds.Username + ":" + creds.Password)),
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		secret, err := corev1.NewSecret(ctx, name, &corev1.SecretArgs{
+			Metadata: metav1.ObjectMetaArgs{
+				Name: pulumi.Sprintf("%s-%s-%s", name, ctx.Stack(), seed),
+				Labels: pulumi.StringMap{
+					"chall-manager.ctfer.io/identity": pulumi.String(seed),
+				},
+			},
+			Type: pulumi.String("kubernetes.io/dockerconfigjson"),
+			StringData: pulumi.StringMap{
+				".dockerconfigjson": pulumi.ToSecret(pulumi.String(cfg)).(pulumi.StringOutput),
+			},
+		}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, corev1.LocalObjectReferenceArgs{
+			Name: secret.Metadata.Name(),
+		})
+	}
+
+	return func(pod *corev1.PodSpecArgs, _ *corev1.ContainerArgs) {
+		if len(refs) != 0 {
+			pod.ImagePullSecrets = refs
+		}
+	}, nil
+}
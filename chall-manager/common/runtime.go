@@ -0,0 +1,45 @@
+package common
+
+import (
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// RuntimeArgs overrides how a container runs its image, such that a generic
+// image could be reused with a different entrypoint.
+type RuntimeArgs struct {
+	// The entrypoint of the container, replacing the image one.
+	Command []string `form:"command" json:"command,omitempty" override:"locked"`
+
+	// The arguments of the entrypoint, replacing the image ones.
+	Args []string `form:"args" json:"args,omitempty" override:"locked"`
+
+	// The working directory of the container, replacing the image one.
+	WorkingDir string `form:"workingDir" json:"workingDir,omitempty"`
+
+	// The user ID to run the container processes as, replacing the image one.
+	RunAsUser *int `form:"runAsUser" json:"runAsUser,omitempty" validate:"omitempty,min=0" override:"locked"`
+
+	// The group ID to run the container processes as, replacing the image
+	// one.
+	RunAsGroup *int `form:"runAsGroup" json:"runAsGroup,omitempty" validate:"omitempty,min=0" override:"locked"`
+}
+
+// Patch sets the overrides on the container, as a [PodPatch].
+func (args RuntimeArgs) Patch(_ *corev1.PodSpecArgs, ctr *corev1.ContainerArgs) {
+	if len(args.Command) != 0 {
+		ctr.Command = pulumi.ToStringArray(args.Command)
+	}
+	if len(args.Args) != 0 {
+		ctr.Args = pulumi.ToStringArray(args.Args)
+	}
+	if args.WorkingDir != "" {
+		ctr.WorkingDir = pulumi.String(args.WorkingDir)
+	}
+	if args.RunAsUser != nil || args.RunAsGroup != nil {
+		ctr.SecurityContext = &corev1.SecurityContextArgs{
+			RunAsUser:  pulumi.IntPtrFromPtr(args.RunAsUser),
+			RunAsGroup: pulumi.IntPtrFromPtr(args.RunAsGroup),
+		}
+	}
+}
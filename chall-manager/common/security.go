@@ -0,0 +1,246 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/pkg/errors"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Profile is a security profile, after the Kubernetes Pod Security
+// Standards, from the least to the most restrictive.
+type Profile string
+
+const (
+	// ProfilePrivileged sets no restriction.
+	ProfilePrivileged Profile = "privileged"
+
+	// ProfileBaseline prevents known privilege escalations: no privileged
+	// container, no capability beyond the container runtime default ones,
+	// and no unconfined seccomp profile.
+	ProfileBaseline Profile = "baseline"
+
+	// ProfileRestricted follows the hardening best practices on top of
+	// [ProfileBaseline]: no privilege escalation, non-root user, all
+	// capabilities dropped but `NET_BIND_SERVICE`, and the `RuntimeDefault`
+	// seccomp profile.
+	ProfileRestricted Profile = "restricted"
+)
+
+var profiles = []Profile{ProfilePrivileged, ProfileBaseline, ProfileRestricted}
+
+// level of restriction of the profile.
+func (p Profile) level() int {
+	return slices.Index(profiles, p)
+}
+
+// baselineCapabilities are the capabilities the baseline profile allows to
+// add, i.e. the container runtime default ones.
+var baselineCapabilities = []string{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// MinProfileEnv is the environment variable a platform operator sets on
+// chall-manager to enforce a minimum security profile on the recipes,
+// whatever their configuration asks.
+const MinProfileEnv = "RECIPES_MIN_SECURITY_PROFILE"
+
+// MinProfile returns the minimum security profile the platform operator
+// enforces (see [MinProfileEnv]), [ProfilePrivileged] if none.
+func MinProfile() (Profile, error) {
+	minimum := Profile(os.Getenv(MinProfileEnv))
+	if minimum == "" {
+		return ProfilePrivileged, nil
+	}
+	if minimum.level() < 0 {
+		return "", errors.Errorf("invalid %s value %q, expected one of %v", MinProfileEnv, minimum, profiles)
+	}
+	return minimum, nil
+}
+
+// SecurityArgs hardens the security context of a container, with a named
+// profile and explicit overrides of its settings.
+type SecurityArgs struct {
+	// The security profile, defining the defaults of the other settings:
+	// `privileged`, `baseline` or `restricted`.
+	// The platform operator could enforce a more restrictive one.
+	Profile Profile `form:"profile" json:"profile,omitempty" validate:"omitempty,oneof=privileged baseline restricted" default:"baseline"`
+
+	// Whether to run the container as privileged, i.e. with all the host
+	// capabilities.
+	Privileged *bool `form:"privileged" json:"privileged,omitempty"`
+
+	// Whether a process could gain more privileges than its parent (e.g.
+	// through setuid binaries). Defaults to false with the `restricted`
+	// profile.
+	AllowPrivilegeEscalation *bool `form:"allowPrivilegeEscalation" json:"allowPrivilegeEscalation,omitempty"`
+
+	// Whether the container must run as a non-root user, such that it fails
+	// to start otherwise. Defaults to true with the `restricted` profile.
+	RunAsNonRoot *bool `form:"runAsNonRoot" json:"runAsNonRoot,omitempty"`
+
+	// Whether to mount the root filesystem of the container as read-only.
+	ReadOnlyRootFilesystem *bool `form:"readOnlyRootFilesystem" json:"readOnlyRootFilesystem,omitempty"`
+
+	// The seccomp profile to run the container with, `RuntimeDefault` or
+	// `Unconfined`. Defaults to `RuntimeDefault` with the `restricted`
+	// profile.
+	SeccompProfile string `form:"seccompProfile" json:"seccompProfile,omitempty" validate:"omitempty,oneof=RuntimeDefault Unconfined"`
+
+	// The Linux capabilities to add to and drop from the container.
+	Capabilities CapabilitiesArgs `form:"capabilities" json:"capabilities,omitempty"`
+}
+
+// CapabilitiesArgs defines the Linux capabilities of a container, without
+// their `CAP_` prefix (e.g. `NET_ADMIN`).
+type CapabilitiesArgs struct {
+	// The capabilities to add.
+	Add []string `form:"add" json:"add,omitempty" validate:"dive,required"`
+
+	// The capabilities to drop, `ALL` for all of them. Defaults to `ALL`
+	// with the `restricted` profile.
+	Drop []string `form:"drop" json:"drop,omitempty" validate:"dive,required"`
+}
+
+// Resolve returns the settings of the profile, with the overrides, such
+// that they are at least as restrictive as the minimum profile.
+// It also returns the warnings of what was enforced over the
+// configuration at path (e.g. `security`).
+func (args SecurityArgs) Resolve(path string, minimum Profile) (SecurityArgs, []string) {
+	warns := []string{}
+	enforce := func(key string, v any) {
+		warns = append(warns, fmt.Sprintf("enforcing the %s security profile over %s.%s=%v", minimum, path, key, v))
+	}
+
+	if args.Profile.level() < minimum.level() {
+		enforce("profile", args.Profile)
+		args.Profile = minimum
+	}
+
+	// Profile defaults, unless overridden
+	switch args.Profile {
+	case ProfileRestricted:
+		if args.AllowPrivilegeEscalation == nil {
+			args.AllowPrivilegeEscalation = pulumi.BoolRef(false)
+		}
+		if args.RunAsNonRoot == nil {
+			args.RunAsNonRoot = pulumi.BoolRef(true)
+		}
+		if args.SeccompProfile == "" {
+			args.SeccompProfile = "RuntimeDefault"
+		}
+		if len(args.Capabilities.Drop) == 0 {
+			args.Capabilities.Drop = []string{"ALL"}
+		}
+		fallthrough
+
+	case ProfileBaseline:
+		if args.Privileged == nil {
+			args.Privileged = pulumi.BoolRef(false)
+		}
+	}
+
+	// Enforce the minimum profile over the overrides
+	if minimum.level() >= ProfileBaseline.level() {
+		if args.Privileged != nil && *args.Privileged {
+			enforce("privileged", true)
+			args.Privileged = pulumi.BoolRef(false)
+		}
+		if args.SeccompProfile == "Unconfined" {
+			enforce("seccompProfile", args.SeccompProfile)
+			args.SeccompProfile = "RuntimeDefault"
+		}
+		args.Capabilities.Add = slices.DeleteFunc(slices.Clone(args.Capabilities.Add), func(c string) bool {
+			if slices.Contains(baselineCapabilities, c) {
+				return false
+			}
+			enforce("capabilities.add", c)
+			return true
+		})
+	}
+	if minimum == ProfileRestricted {
+		if *args.AllowPrivilegeEscalation {
+			enforce("allowPrivilegeEscalation", true)
+			args.AllowPrivilegeEscalation = pulumi.BoolRef(false)
+		}
+		if !*args.RunAsNonRoot {
+			enforce("runAsNonRoot", false)
+			args.RunAsNonRoot = pulumi.BoolRef(true)
+		}
+		if !slices.Contains(args.Capabilities.Drop, "ALL") {
+			enforce("capabilities.drop", args.Capabilities.Drop)
+			args.Capabilities.Drop = []string{"ALL"}
+		}
+		args.Capabilities.Add = slices.DeleteFunc(args.Capabilities.Add, func(c string) bool {
+			if c == "NET_BIND_SERVICE" {
+				return false
+			}
+			enforce("capabilities.add", c)
+			return true
+		})
+	}
+	return args, warns
+}
+
+// Patch sets the security context of the container and of the init
+// containers of its pod, as a [PodPatch].
+// The settings should be resolved first (see [SecurityArgs.Resolve]).
+// It completes the security context [RuntimeArgs.Patch] sets, so must run
+// after it.
+func (args SecurityArgs) Patch(pod *corev1.PodSpecArgs, ctr *corev1.ContainerArgs) {
+	args.patch(ctr)
+	if inits, ok := pod.InitContainers.(corev1.ContainerArray); ok {
+		for i := range inits {
+			init := inits[i].(corev1.ContainerArgs)
+			args.patch(&init)
+			inits[i] = init
+		}
+	}
+}
+
+func (args SecurityArgs) patch(ctr *corev1.ContainerArgs) {
+	// Complete the existing security context, if any
+	sc, ok := ctr.SecurityContext.(*corev1.SecurityContextArgs)
+	if !ok {
+		sc = &corev1.SecurityContextArgs{}
+	}
+	set := ok || args.Privileged != nil || args.AllowPrivilegeEscalation != nil || args.RunAsNonRoot != nil ||
+		args.ReadOnlyRootFilesystem != nil || args.SeccompProfile != "" ||
+		len(args.Capabilities.Add) != 0 || len(args.Capabilities.Drop) != 0
+	if !set {
+		return
+	}
+
+	if args.Privileged != nil {
+		sc.Privileged = pulumi.BoolPtrFromPtr(args.Privileged)
+	}
+	if args.AllowPrivilegeEscalation != nil {
+		sc.AllowPrivilegeEscalation = pulumi.BoolPtrFromPtr(args.AllowPrivilegeEscalation)
+	}
+	if args.RunAsNonRoot != nil {
+		sc.RunAsNonRoot = pulumi.BoolPtrFromPtr(args.RunAsNonRoot)
+	}
+	if args.ReadOnlyRootFilesystem != nil {
+		sc.ReadOnlyRootFilesystem = pulumi.BoolPtrFromPtr(args.ReadOnlyRootFilesystem)
+	}
+	if args.SeccompProfile != "" {
+		sc.SeccompProfile = corev1.SeccompProfileArgs{
+			Type: pulumi.String(args.SeccompProfile),
+		}
+	}
+	if len(args.Capabilities.Add) != 0 || len(args.Capabilities.Drop) != 0 {
+		caps := corev1.CapabilitiesArgs{}
+		if len(args.Capabilities.Add) != 0 {
+			caps.Add = pulumi.ToStringArray(args.Capabilities.Add)
+		}
+		if len(args.Capabilities.Drop) != 0 {
+			caps.Drop = pulumi.ToStringArray(args.Capabilities.Drop)
+		}
+		sc.Capabilities = caps
+	}
+	ctr.SecurityContext = sc
+}
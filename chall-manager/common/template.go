@@ -0,0 +1,293 @@
+package common
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"github.com/ctfer-io/recipes"
+)
+
+// Format is the output format of a template, defining how values are
+// escaped.
+type Format string
+
+const (
+	// FormatText outputs values as is.
+	FormatText Format = "text"
+
+	// FormatMarkdown outputs values as is, and provides the `mdEscape`
+	// function to escape Markdown special characters.
+	FormatMarkdown Format = "markdown"
+
+	// FormatHTML outputs values escaped according to their HTML context.
+	FormatHTML Format = "html"
+)
+
+// Values are the instance values available to templates of recipes (e.g.
+// envs and files contents, or connection info).
+type Values struct {
+	// Identity of the instance.
+	Identity string
+
+	// Stack name of the instance.
+	Stack string
+
+	// Hostname used as part of the instance URLs.
+	Hostname string
+
+	// Flag of the instance, i.e. the first of Flags (if any).
+	Flag string
+
+	// Flags of the instance.
+	Flags []string
+
+	// URLs exposed by the instance, whose structure depends on the recipe.
+	// Only available once deployed, e.g. in the connection info.
+	URLs any
+
+	secretFlags bool
+}
+
+// NewValues creates the instance values from its flags.
+// When the flags are secret (see [recipes.Flagger]), so are the contents
+// rendered out of them (see [Values.Secret]).
+func NewValues(identity, stack, hostname string, flags []string, secretFlags bool) *Values {
+	values := &Values{
+		Identity:    identity,
+		Stack:       stack,
+		Hostname:    hostname,
+		Flags:       flags,
+		secretFlags: secretFlags,
+	}
+	if len(flags) != 0 {
+		values.Flag = flags[0]
+	}
+	return values
+}
+
+// Secret returns whether a content rendered with the values is secret, i.e.
+// it contains one of the flags while they are secret.
+func (v *Values) Secret(content string) bool {
+	if v == nil || !v.secretFlags {
+		return false
+	}
+	for _, flag := range v.Flags {
+		if flag != "" && strings.Contains(content, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Template is a parsed Go template, supporting the sprig functions.
+type Template struct {
+	name string
+	tree *parse.Tree
+	exec interface {
+		Execute(io.Writer, any) error
+	}
+}
+
+// TemplateOption configures a [Template].
+type TemplateOption func(*templateOptions)
+
+type templateOptions struct {
+	fallback string
+}
+
+// WithFallback sets the text the helper functions (e.g. `ncCmd`) return when
+// the URL they are given is missing. Defaults to [DefaultFallback].
+func WithFallback(fallback string) TemplateOption {
+	return func(opts *templateOptions) {
+		opts.fallback = fallback
+	}
+}
+
+// NewTemplate parses the Go template content, such that errors are
+// reported before anything is deployed.
+// An empty format defaults to [FormatText].
+// On top of sprig, it provides helper functions to write connection info
+// for common protocols: `host`, `port`, `sshCmd`, `ncCmd`, `httpURL`,
+// `httpsURL` and `openSSLCmd`.
+func NewTemplate(name, content string, format Format, opts ...TemplateOption) (*Template, error) {
+	options := &templateOptions{
+		fallback: DefaultFallback,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	funcs := helpers(options.fallback)
+
+	switch format {
+	case FormatText, "":
+		tmpl, err := template.New(name).
+			Funcs(sprig.FuncMap()).
+			Funcs(funcs).
+			Option("missingkey=error").
+			Parse(content)
+		if err != nil {
+			return nil, err
+		}
+		return &Template{name: name, tree: tmpl.Tree, exec: tmpl}, nil
+
+	case FormatMarkdown:
+		funcs["mdEscape"] = mdEscape
+		tmpl, err := template.New(name).
+			Funcs(sprig.FuncMap()).
+			Funcs(funcs).
+			Option("missingkey=error").
+			Parse(content)
+		if err != nil {
+			return nil, err
+		}
+		return &Template{name: name, tree: tmpl.Tree, exec: tmpl}, nil
+
+	case FormatHTML:
+		tmpl, err := htmltemplate.New(name).
+			Funcs(sprig.FuncMap()).
+			Funcs(funcs).
+			Option("missingkey=error").
+			Parse(content)
+		if err != nil {
+			return nil, err
+		}
+		return &Template{name: name, tree: tmpl.Tree, exec: tmpl}, nil
+	}
+	return nil, errors.Errorf("unsupported template format %q", format)
+}
+
+// Execute renders the template with the values.
+func (tmpl *Template) Execute(values any) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := tmpl.exec.Execute(buf, values); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// walkText replaces the literal text of the template by the result of f.
+func (tmpl *Template) walkText(f func(string) string) {
+	if tmpl.tree == nil {
+		return
+	}
+	walkText(tmpl.tree.Root, f)
+}
+
+func walkText(node parse.Node, f func(string) string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, sub := range n.Nodes {
+			walkText(sub, f)
+		}
+	case *parse.TextNode:
+		n.Text = []byte(f(string(n.Text)))
+	case *parse.IfNode:
+		walkText(&n.BranchNode, f)
+	case *parse.RangeNode:
+		walkText(&n.BranchNode, f)
+	case *parse.WithNode:
+		walkText(&n.BranchNode, f)
+	case *parse.BranchNode:
+		walkText(n.List, f)
+		walkText(n.ElseList, f)
+	}
+}
+
+// ReferencesFlags returns whether the template uses the `.Flag` or `.Flags`
+// values.
+func (tmpl *Template) ReferencesFlags() bool {
+	if tmpl.tree == nil {
+		return false
+	}
+	return referencesFlags(tmpl.tree.Root)
+}
+
+func referencesFlags(node parse.Node) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, sub := range n.Nodes {
+			if referencesFlags(sub) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return referencesFlags(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if referencesFlags(cmd) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if referencesFlags(arg) {
+				return true
+			}
+		}
+	case *parse.FieldNode:
+		return len(n.Ident) != 0 && (n.Ident[0] == "Flag" || n.Ident[0] == "Flags")
+	case *parse.ChainNode:
+		return referencesFlags(n.Node)
+	case *parse.IfNode:
+		return referencesFlags(&n.BranchNode)
+	case *parse.RangeNode:
+		return referencesFlags(&n.BranchNode)
+	case *parse.WithNode:
+		return referencesFlags(&n.BranchNode)
+	case *parse.BranchNode:
+		return referencesFlags(n.Pipe) || referencesFlags(n.List) || referencesFlags(n.ElseList)
+	case *parse.TemplateNode:
+		return referencesFlags(n.Pipe)
+	}
+	return false
+}
+
+// ConnectionInfo renders the template once the URLs are resolved, with the
+// values completed by them.
+// The URLs type U is the one of the recipe (e.g. map[string]string).
+// Execution errors are [recipes.TemplateError] on the template name.
+// The output is secret when the template references secret flags.
+func ConnectionInfo[U any](tmpl *Template, values *Values, urls pulumi.Output) pulumi.StringOutput {
+	out := urls.ApplyT(func(urls U) (string, error) {
+		v := *values
+		v.URLs = urls
+		out, err := tmpl.Execute(&v)
+		if err != nil {
+			return "", &recipes.TemplateError{Path: tmpl.name, Err: err}
+		}
+		return out, nil
+	}).(pulumi.StringOutput)
+	if values.secretFlags && tmpl.ReferencesFlags() {
+		return pulumi.ToSecret(out).(pulumi.StringOutput)
+	}
+	return out
+}
+
+var mdReplacer = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`,
+	`{`, `\{`, `}`, `\}`, `[`, `\[`, `]`, `\]`,
+	`(`, `\(`, `)`, `\)`, `#`, `\#`, `+`, `\+`,
+	`-`, `\-`, `.`, `\.`, `!`, `\!`, `|`, `\|`,
+	`<`, `\<`, `>`, `\>`,
+)
+
+func mdEscape(s string) string {
+	return mdReplacer.Replace(s)
+}
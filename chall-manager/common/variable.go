@@ -1,6 +1,11 @@
 package common
 
-import "github.com/ctfer-io/chall-manager/sdk"
+import (
+	"github.com/ctfer-io/chall-manager/sdk"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"github.com/ctfer-io/recipes"
+)
 
 // Variable represent a content that can be variated.
 type Variable struct {
@@ -10,12 +15,61 @@ type Variable struct {
 	// Whether to variate the content according per a PRNG seeded by the instance's identity (reproducible).
 	Variate bool `form:"variate" json:"variate"`
 
+	// Whether the content of an env or file is a Go template rendered with
+	// the instance values (e.g. `{{ .Flag }}`), rather than a literal.
+	Template bool `form:"template" json:"template"`
+
+	// Whether the content is secret (e.g. the flag, or an API key), such that
+	// it is encrypted in the Pulumi state and redacted from logs.
+	Secret bool `form:"secret" json:"secret"`
+
 	// Variation functional options
 
+	// Whether to use lowercase characters in variation. Defaults to true.
 	Lowercase *bool `form:"lowercase" json:"lowercase,omitempty"`
+	// Whether to use uppercase characters in variation. Defaults to true.
 	Uppercase *bool `form:"uppercase" json:"uppercase,omitempty"`
-	Numeric   *bool `form:"numeric"   json:"numeric,omitempty"`
-	Special   *bool `form:"special"   json:"special,omitempty"`
+	// Whether to use numeric characters in variation. Defaults to true.
+	Numeric *bool `form:"numeric" json:"numeric,omitempty"`
+	// Whether to use special characters in variation. Defaults to false.
+	Special *bool `form:"special" json:"special,omitempty"`
+}
+
+var _ recipes.Secreter = (*Variable)(nil)
+
+// IsSecret returns whether the content is secret.
+func (v Variable) IsSecret() bool {
+	return v.Secret
+}
+
+// ToInput returns the produced content as a Pulumi input, marked as secret
+// if the variable is, or if it contains secret flags (see [Values.Secret]).
+func (v Variable) ToInput(content string, values *Values) pulumi.StringInput {
+	if v.Secret || values.Secret(content) {
+		return pulumi.ToSecret(pulumi.String(content)).(pulumi.StringOutput)
+	}
+	return pulumi.String(content)
+}
+
+// Render the content with the values if it is a template, else produce it
+// (see [Variable.Produce]).
+// Only the literal text of the template is variated, such that the values
+// it injects (e.g. an already variated flag) are left as is.
+func (v Variable) Render(seed string, values *Values) (string, error) {
+	if !v.Template {
+		return v.Produce(seed), nil
+	}
+
+	tmpl, err := NewTemplate("variable", v.Content, FormatText)
+	if err != nil {
+		return "", err
+	}
+	if v.Variate {
+		tmpl.walkText(func(text string) string {
+			return v.variate(seed, text)
+		})
+	}
+	return tmpl.Execute(values)
 }
 
 // Produce the content given its configuration, and a seed (should be the instance identity
@@ -24,8 +78,11 @@ func (v Variable) Produce(seed string) string {
 	if !v.Variate {
 		return v.Content
 	}
+	return v.variate(seed, v.Content)
+}
 
-	return sdk.Variate(seed, v.Content,
+func (v Variable) variate(seed, content string) string {
+	return sdk.Variate(seed, content,
 		sdk.WithLowercase(v.Lowercase == nil || *v.Lowercase),
 		sdk.WithUppercase(v.Uppercase == nil || *v.Uppercase),
 		sdk.WithNumeric(v.Numeric == nil || *v.Numeric),
@@ -2,26 +2,103 @@ package main
 
 import (
 	"encoding/json"
+	"maps"
+	"reflect"
+	"strings"
 
 	"github.com/ctfer-io/chall-manager/sdk"
 	"github.com/ctfer-io/recipes"
+	"github.com/pkg/errors"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"github.com/ctfer-io/recipes/chall-manager/common/catalog"
 )
 
 // This scenario is made to debug automation.
 //
-// It returns the input configuration in the connection info such that the concerns
-// boundaries could be crossed, thus ease debug.
+// It returns the input request (identity, metadata and raw additional values) in the
+// connection info such that the concerns boundaries could be crossed, thus ease debug.
+// Values marked as secret (e.g. `flag.secret=true`) are redacted.
+//
+// When the `debug.recipe` additional value is set to a recipe name (e.g. `k8s.E1P`),
+// it rather dry-runs this recipe with the other additional values, and returns a report
+// of what it would deploy (as text, or JSON if `debug.format=json`).
+
+const (
+	// RecipeKey is the additional value of the recipe to dry-run.
+	RecipeKey = "debug.recipe"
+
+	// FormatKey is the additional value of the dry-run report format, `text` (default)
+	// or `json`.
+	FormatKey = "debug.format"
+)
 
 type Config map[string]string
 
 func main() {
-	recipes.Run(func(req *recipes.Request[Config], resp *sdk.Response, opts ...pulumi.ResourceOption) error {
-		conf, err := json.Marshal(req.Config)
-		if err != nil {
-			return err
+	recipes.Run(factory)
+}
+
+func factory(req *recipes.Request[Config], resp *sdk.Response, opts ...pulumi.ResourceOption) error {
+	if name, ok := req.Additional[RecipeKey]; ok {
+		return dryRun(req, resp, name)
+	}
+
+	meta, err := json.Marshal(req.Metadata)
+	if err != nil {
+		return err
+	}
+	// Redact the secret fields of any recipe, as the values could target one
+	types := []reflect.Type{}
+	for _, name := range catalog.Names() {
+		r, _ := catalog.Get(name)
+		types = append(types, r.Type())
+	}
+	conf, err := json.Marshal(recipes.RedactAdditionals(req.Additional, types...))
+	if err != nil {
+		return err
+	}
+	resp.ConnectionInfo = pulumi.Sprintf("Identity: %s\nMetadata: %s\nConfiguration: %s", req.Identity, meta, conf)
+	return nil
+}
+
+func dryRun(req *recipes.Request[Config], resp *sdk.Response, name string) error {
+	r, ok := catalog.Get(name)
+	if !ok {
+		return &recipes.ValidationError{
+			Fields: []*recipes.FieldError{{
+				Path: RecipeKey,
+				Rule: "oneof=" + strings.Join(catalog.Names(), " "),
+			}},
+			Err: errors.Errorf("%s: unknown recipe %s", RecipeKey, name),
+		}
+	}
+
+	// Pass the additional values to the recipe, but the debug ones
+	additionals := maps.Clone(req.Additional)
+	delete(additionals, RecipeKey)
+	delete(additionals, FormatKey)
+
+	rep := catalog.Preview(r, req.Identity, additionals)
+	var out string
+	var err error
+	switch format := req.Additional[FormatKey]; format {
+	case "", "text":
+		out, err = rep.Text()
+	case "json":
+		out, err = rep.JSON()
+	default:
+		return &recipes.ValidationError{
+			Fields: []*recipes.FieldError{{
+				Path: FormatKey,
+				Rule: "oneof=text json",
+			}},
+			Err: errors.Errorf("%s: unsupported format %s", FormatKey, format),
 		}
-		resp.ConnectionInfo = pulumi.Sprintf("Configuration: %s", conf)
-		return nil
-	})
+	}
+	if err != nil {
+		return err
+	}
+	resp.ConnectionInfo = pulumi.String(out).ToStringOutput()
+	return nil
 }
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ctfer-io/recipes/dryrun"
+)
+
+func Test_U_Factory(t *testing.T) {
+	t.Parallel()
+
+	const expectedPrefix = "Identity: a0b1c2d3\n" +
+		`Metadata: {"project":"dryrun","stack":"dryrun","organization":"organization","dryRun":false}` + "\n"
+
+	var tests = map[string]struct {
+		Additionals            map[string]string
+		ExpectedConnectionInfo string
+	}{
+		"empty": {
+			Additionals:            map[string]string{},
+			ExpectedConnectionInfo: expectedPrefix + "Configuration: {}",
+		},
+		"values": {
+			Additionals: map[string]string{
+				"image": "nginx:latest",
+			},
+			ExpectedConnectionInfo: expectedPrefix + `Configuration: {"image":"nginx:latest"}`,
+		},
+		"secret": {
+			Additionals: map[string]string{
+				"flag.content": "CTF{some-flag}",
+				"flag.secret":  "true",
+			},
+			ExpectedConnectionInfo: expectedPrefix + `Configuration: {"flag.content":"[REDACTED]","flag.secret":"true"}`,
+		},
+		"secret-document": {
+			Additionals: map[string]string{
+				"config": "flag:\n  content: CTF{some-flag}\n  secret: true\n",
+			},
+			ExpectedConnectionInfo: expectedPrefix + `Configuration: {"config":"{\"flag\":{\"content\":\"[REDACTED]\",\"secret\":true}}"}`,
+		},
+		"secret-field": {
+			Additionals: map[string]string{
+				"registry.server":   "registry.ctfer.io",
+				"registry.username": "ctfer",
+				"registry.password": "s3cr3t",
+			},
+			ExpectedConnectionInfo: expectedPrefix + `Configuration: {"registry.password":"[REDACTED]","registry.server":"registry.ctfer.io","registry.username":"ctfer"}`,
+		},
+		"secret-field-document": {
+			Additionals: map[string]string{
+				"config": "containers:\n  app:\n    image: nginx:latest\nregistry:\n  server: registry.ctfer.io\n  username: ctfer\n  password: s3cr3t\n",
+			},
+			ExpectedConnectionInfo: expectedPrefix + `Configuration: {"config":"{\"containers\":{\"app\":{\"image\":\"nginx:latest\"}},\"registry\":{\"password\":\"[REDACTED]\",\"server\":\"registry.ctfer.io\",\"username\":\"ctfer\"}}"}`,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := dryrun.Run(factory, "a0b1c2d3", tt.Additionals)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.ExpectedConnectionInfo, res.ConnectionInfo)
+			assert.NotContains(t, res.ConnectionInfo, "s3cr3t")
+			assert.Empty(t, res.Resources)
+		})
+	}
+}
+
+func Test_U_DryRun(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Additionals map[string]string
+		ExpectErr   bool
+		Contains    []string
+	}{
+		"unknown-recipe": {
+			Additionals: map[string]string{
+				"debug.recipe": "k8s.Unknown",
+			},
+			ExpectErr: true,
+		},
+		"unsupported-format": {
+			Additionals: map[string]string{
+				"debug.recipe": "k8s.E1P",
+				"debug.format": "yaml",
+			},
+			ExpectErr: true,
+		},
+		"invalid-configuration": {
+			Additionals: map[string]string{
+				"debug.recipe": "k8s.E1P",
+				"image":        "pandatix/license-lvl1:latest",
+			},
+			Contains: []string{
+				"Recipe: k8s.E1P\n",
+				"Errors:\n",
+				"- hostname: required\n",
+			},
+		},
+		"provisioning-error": {
+			Additionals: map[string]string{
+				"debug.recipe":          "k8s.EMP",
+				"containers[app].image": "nginx:latest",
+				"hostname":              "ctfer.io",
+			},
+			Contains: []string{
+				"Errors:\n- recipe dryrun: provisioning: no port exposed\n",
+			},
+		},
+		"text": {
+			Additionals: map[string]string{
+				"debug.recipe":        "k8s.E1P",
+				"image":               "pandatix/license-lvl1:latest",
+				"ports[0].port":       "8080",
+				"hostname":            "ctfer.io",
+				"envs[FLAG].content":  "{{ .Flag }}",
+				"envs[FLAG].template": "true",
+				"envs[FLAG].secret":   "true",
+				"flag.content":        "CTF{some-flag}",
+			},
+			Contains: []string{
+				"Recipe: k8s.E1P\n",
+				`"FLAG": "[REDACTED]"`,
+				"Connection info:\n8080/TCP: ctfer.io:32544\n",
+				"- CTF{some-flag}\n",
+				"- kubernetes:apps/v1:Deployment emp-dep-one\n",
+			},
+		},
+		"json": {
+			Additionals: map[string]string{
+				"debug.recipe":                        "k8s.EMP",
+				"debug.format":                        "json",
+				"containers[app].image":               "nginx:latest",
+				"containers[app].ports[0].port":       "80",
+				"containers[app].ports[0].exposeType": "NodePort",
+				"hostname":                            "ctfer.io",
+			},
+			Contains: []string{
+				`"recipe": "k8s.EMP"`,
+				`"connectionInfo": "app 80/TCP: ctfer.io:30080\n"`,
+				`"name": "emp-dep-app"`,
+			},
+		},
+		"secret-field": {
+			Additionals: map[string]string{
+				"debug.recipe":      "k8s.E1P",
+				"image":             "pandatix/license-lvl1:latest",
+				"ports[0].port":     "8080",
+				"hostname":          "ctfer.io",
+				"registry.server":   "registry.ctfer.io",
+				"registry.username": "ctfer",
+				"registry.password": "s3cr3t",
+			},
+			Contains: []string{
+				`"password": "[REDACTED]"`,
+				"- kubernetes:core/v1:Secret ",
+			},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := dryrun.Run(factory, "a0b1c2d3", tt.Additionals)
+			if tt.ExpectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			for _, s := range tt.Contains {
+				assert.Contains(t, res.ConnectionInfo, s)
+			}
+			assert.NotContains(t, res.ConnectionInfo, "s3cr3t")
+			assert.Empty(t, res.Resources)
+		})
+	}
+}
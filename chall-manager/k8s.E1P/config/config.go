@@ -1,6 +1,12 @@
 package config
 
 import (
+	"fmt"
+
+	"go.uber.org/multierr"
+
+	k8s "github.com/ctfer-io/chall-manager/sdk/kubernetes"
+	"github.com/ctfer-io/recipes"
 	common "github.com/ctfer-io/recipes/chall-manager/common"
 )
 
@@ -8,18 +14,126 @@ import (
 type Config struct {
 	// Inputs
 
-	Image            string                     `form:"image"            json:"image"`
-	Ports            []common.PortArgs          `form:"ports"            json:"ports"`
-	Envs             map[string]common.Variable `form:"envs"             json:"envs,omitempty"`
-	Hostname         string                     `form:"hostname"         json:"hostname"`
-	Files            map[string]common.Variable `form:"files"            json:"files,omitempty"`
-	FromCIDR         string                     `form:"fromCidr"         json:"fromCidr"`
-	IngressNamespace string                     `form:"ingressNamespace" json:"ingressNamespace"`
-	IngressLabels    map[string]string          `form:"ingressLabels"    json:"ingressLabels,omitempty"`
-	Requests         map[string]string          `form:"requests"         json:"requests,omitempty"`
-	Limits           map[string]string          `form:"limits"           json:"limits,omitempty"`
+	// The Docker image reference to deploy.
+	Image string `form:"image" json:"image" validate:"required" override:"locked"`
+
+	// How to pull the images from private registries.
+	common.RegistryArgs
+
+	// How the container runs its image (e.g. its command).
+	common.RuntimeArgs
+
+	// How Kubernetes checks the health of the container.
+	common.ProbesArgs
+
+	// How the container is hardened, with a security profile (e.g.
+	// `security.profile=restricted`) and explicit overrides of its settings.
+	// It applies to the init containers too.
+	Security common.SecurityArgs `form:"security" json:"security" override:"locked"`
+
+	// The ports, protocols and expose types of the container, at least one.
+	// They are exposed with `exposeType=NodePort` unless set otherwise.
+	Ports []common.PortArgs `form:"ports" json:"ports" validate:"required,min=1,dive"`
+
+	// The environment variables to pass to the container.
+	// Their contents could be Go templates rendered with the instance values.
+	Envs map[string]common.Variable `form:"envs" json:"envs,omitempty"`
+
+	// The hostname to use as part of URLs in the connection info.
+	Hostname string `form:"hostname" json:"hostname" validate:"required"`
+
+	// The files to mount in the container, identified by their absolute path.
+	// Their contents could be Go templates rendered with the instance values.
+	Files map[string]common.Variable `form:"files" json:"files,omitempty" validate:"omitempty,dive,keys,startswith=/,endkeys"`
+
+	// The containers to run to completion, in order, before the container
+	// starts (e.g. to seed a database with the flag).
+	InitContainers []common.InitContainerArgs `form:"initContainers" json:"initContainers,omitempty" validate:"dive"`
+
+	// A CIDR from which to restrict access to the challenge.
+	FromCIDR string `form:"fromCidr" json:"fromCidr" validate:"omitempty,cidr"`
+
+	// The namespace of the ingress controller to grant network access from.
+	// Required if any port uses `exposeType=Ingress`.
+	IngressNamespace string `form:"ingressNamespace" json:"ingressNamespace"`
+
+	// The labels of the ingress controller pods to grant network access from.
+	// Required if any port uses `exposeType=Ingress`.
+	IngressLabels map[string]string `form:"ingressLabels" json:"ingressLabels,omitempty"`
+
+	// On which nodes the pod is scheduled.
+	common.PlacementArgs
+
+	// The resource requests of the container.
+	Requests map[string]string `form:"requests" json:"requests,omitempty" default:"cpu=100m,memory=128Mi" override:"locked"`
+
+	// The resource limits of the container.
+	Limits map[string]string `form:"limits" json:"limits,omitempty" default:"cpu=500m,memory=256Mi" override:"locked"`
 
 	// Outputs
 
+	// The Go template of the connection info to return for each instance,
+	// rendered with the instance values completed by `.URLs` (e.g.
+	// `http://{{ index .URLs "8080/TCP" }}` for a container that listens on
+	// port 8080 over TCP). It supports the sprig and helper functions.
+	// Defaults to listing all URLs, one per line.
 	ConnectionInfo string `form:"connectionInfo" json:"connectionInfo"`
+
+	// The output format of the connection info, defining how values are
+	// escaped: `text` (as is), `markdown` (as is, with the `mdEscape` function
+	// to escape Markdown special characters) or `html` (escaped according to
+	// the HTML context).
+	ConnectionInfoFormat common.Format `form:"connectionInfoFormat" json:"connectionInfoFormat" validate:"omitempty,oneof=text markdown html" default:"text"`
+
+	// The text the connection info helper functions (e.g. `ncCmd`) return
+	// when the URL they are given is missing (e.g. the port is not exposed).
+	ConnectionInfoFallback string `form:"connectionInfoFallback" json:"connectionInfoFallback"`
+
+	// The flags to return for each instance.
+	common.FlagsArgs
+}
+
+// DefaultConnectionInfo lists all URLs exposed by the container, one per line.
+const DefaultConnectionInfo = `{{ range $port, $url := .URLs -}}
+{{ $port }}: {{ $url }}
+{{ end -}}`
+
+// Defaults sets the values that could not be expressed as struct tags.
+func (conf *Config) Defaults() {
+	for i := range conf.Ports {
+		// Ports of a monopod are meant to be reached by players
+		if conf.Ports[i].ExposeType == k8s.ExposeInternal {
+			conf.Ports[i].ExposeType = k8s.ExposeNodePort
+		}
+	}
+	if conf.ConnectionInfo == "" {
+		conf.ConnectionInfo = DefaultConnectionInfo
+	}
+	if conf.ConnectionInfoFallback == "" {
+		conf.ConnectionInfoFallback = common.DefaultFallback
+	}
+}
+
+// Validate checks the constraints that span over multiple fields.
+func (conf Config) Validate() (merr error) {
+	merr = conf.ProbesArgs.Check("")
+	for i, port := range conf.Ports {
+		if port.ExposeType != k8s.ExposeIngress {
+			continue
+		}
+		if conf.IngressNamespace == "" {
+			merr = multierr.Append(merr, &recipes.FieldError{
+				Path: "ingressNamespace",
+				Rule: fmt.Sprintf("required as ports[%d] is exposed through an Ingress", i),
+			})
+		}
+		if len(conf.IngressLabels) == 0 {
+			merr = multierr.Append(merr, &recipes.FieldError{
+				Path: "ingressLabels",
+				Rule: fmt.Sprintf("required as ports[%d] is exposed through an Ingress", i),
+			})
+		}
+		break
+	}
+	return
 }
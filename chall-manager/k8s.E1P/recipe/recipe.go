@@ -0,0 +1,118 @@
+// Package recipe deploys a Kubernetes ExposedMonopod from the recipe configuration.
+package recipe
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"github.com/ctfer-io/chall-manager/sdk"
+	k8s "github.com/ctfer-io/chall-manager/sdk/kubernetes"
+	"github.com/ctfer-io/recipes"
+	"github.com/ctfer-io/recipes/chall-manager/common"
+	"github.com/ctfer-io/recipes/chall-manager/k8s.E1P/config"
+)
+
+// Factory deploys the Kubernetes ExposedMonopod.
+func Factory(req *recipes.Request[config.Config], resp *sdk.Response, opts ...pulumi.ResourceOption) error {
+	// Build template ASAP -> fail fast
+	citmpl, err := common.NewTemplate("connectionInfo", req.Config.ConnectionInfo, req.Config.ConnectionInfoFormat,
+		common.WithFallback(req.Config.ConnectionInfoFallback),
+	)
+	if err != nil {
+		return &recipes.TemplateError{Path: "connectionInfo", Err: err}
+	}
+
+	// Render envs and files with the instance values
+	values := common.NewValues(req.Identity, req.Ctx.Stack(), req.Config.Hostname, req.Flags, req.SecretFlags)
+	envs := k8s.PrinterMap{}
+	for k, v := range req.Config.Envs {
+		content, err := v.Render(req.Identity, values)
+		if err != nil {
+			return &recipes.TemplateError{Path: fmt.Sprintf("envs[%s]", k), Err: err}
+		}
+		envs[k] = k8s.PrinterArgs{
+			Fmt:      v.ToInput(content, values),
+			Services: pulumi.StringArray{},
+		}
+	}
+	files := pulumi.StringMap{}
+	for path, f := range req.Config.Files {
+		content, err := f.Render(req.Identity, values)
+		if err != nil {
+			return &recipes.TemplateError{Path: fmt.Sprintf("files[%s]", path), Err: err}
+		}
+		files[path] = f.ToInput(content, values)
+	}
+
+	// Render init containers, with their own files
+	inits, err := common.InitContainers(req.Ctx, "e1p-init", "initContainers", req.Config.InitContainers, req.Identity, values, opts...)
+	if err != nil {
+		return err
+	}
+
+	// Pull images from private registries
+	pullSecrets, err := common.ImagePullSecrets(req.Ctx, "e1p-registry", req.Config.RegistryArgs, req.Identity, opts...)
+	if err != nil {
+		return err
+	}
+
+	// Harden containers, at least as much as the platform operator requires
+	minProfile, err := common.MinProfile()
+	if err != nil {
+		return err
+	}
+	security, warns := req.Config.Security.Resolve("security", minProfile)
+	for _, warn := range warns {
+		_ = req.Ctx.Log.Warn(warn, nil)
+	}
+
+	// Set what the SDK does not expose
+	opts = append(opts, common.PatchPods(map[string]common.PodPatch{
+		common.MonopodContainer: common.Patches(
+			req.Config.RuntimeArgs.Patch,
+			req.Config.ProbesArgs.Patch,
+			inits,
+			pullSecrets,
+			req.Config.PlacementArgs.Patch(common.MonopodContainer),
+			security.Patch, // after the runtime and init containers patches
+		),
+	}))
+
+	// Deploy k8s.ExposedMonopod
+	cm, err := k8s.NewExposedMonopod(req.Ctx, "recipe-k8s-e1p", &k8s.ExposedMonopodArgs{
+		Identity: pulumi.String(req.Identity),
+		Label:    pulumi.String(req.Ctx.Stack()),
+		Hostname: pulumi.String(req.Config.Hostname),
+		Container: k8s.ContainerArgs{
+			Image: pulumi.String(req.Config.Image),
+			Ports: func() k8s.PortBindingArray {
+				out := make([]k8s.PortBindingInput, 0, len(req.Config.Ports))
+				for _, port := range req.Config.Ports {
+					out = append(out, k8s.PortBindingArgs{
+						Port:        pulumi.Int(port.Port),
+						Protocol:    pulumi.String(port.Protocol),
+						ExposeType:  port.ExposeType,
+						Annotations: pulumi.ToStringMap(port.Annotations),
+					})
+				}
+				return out
+			}(),
+			Envs:     envs,
+			Files:    files,
+			Requests: pulumi.ToStringMap(req.Config.Requests),
+			Limits:   pulumi.ToStringMap(req.Config.Limits),
+		},
+		FromCIDR:         pulumi.String(req.Config.FromCIDR),
+		IngressNamespace: pulumi.String(req.Config.IngressNamespace),
+		IngressLabels:    pulumi.ToStringMap(req.Config.IngressLabels),
+	}, opts...)
+	if err != nil {
+		return err
+	}
+
+	// Template connection info
+	resp.ConnectionInfo = common.ConnectionInfo[map[string]string](citmpl, values, cm.URLs)
+
+	return nil
+}
@@ -0,0 +1,827 @@
+package recipe
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ctfer-io/chall-manager/sdk"
+	"github.com/ctfer-io/recipes"
+	"github.com/ctfer-io/recipes/chall-manager/common"
+	"github.com/ctfer-io/recipes/chall-manager/k8s.E1P/config"
+	"github.com/ctfer-io/recipes/dryrun"
+)
+
+func Test_U_Factory(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Additionals            map[string]string
+		ExpectErr              bool
+		ExpectedErrKind        string
+		ExpectedErrPaths       []string
+		ExpectedConnectionInfo string
+		ExpectedFlags          []string
+		ExpectedEnvs           []any
+	}{
+		"empty": {
+			Additionals: map[string]string{},
+			ExpectErr:   true,
+		},
+		"missing-hostname": {
+			Additionals: map[string]string{
+				"image":          "pandatix/license-lvl1:latest",
+				"ports[0].port":  "8080",
+				"connectionInfo": `http://{{ index .URLs "8080/TCP" }}`,
+			},
+			ExpectErr:        true,
+			ExpectedErrKind:  recipes.KindValidation,
+			ExpectedErrPaths: []string{"hostname"},
+		},
+		"invalid-port": {
+			Additionals: map[string]string{
+				"image":         "pandatix/license-lvl1:latest",
+				"ports[0].port": "http",
+				"hostname":      "ctfer.io",
+			},
+			ExpectErr:        true,
+			ExpectedErrKind:  recipes.KindDecode,
+			ExpectedErrPaths: []string{"ports[0].port"},
+		},
+		"ingress-without-controller": {
+			Additionals: map[string]string{
+				"image":               "pandatix/license-lvl1:latest",
+				"ports[0].port":       "8080",
+				"ports[0].exposeType": "Ingress",
+				"hostname":            "ctfer.io",
+			},
+			ExpectErr:        true,
+			ExpectedErrKind:  recipes.KindValidation,
+			ExpectedErrPaths: []string{"ingressNamespace", "ingressLabels"},
+		},
+		"basic": {
+			Additionals: map[string]string{
+				"image":          "pandatix/license-lvl1:latest",
+				"ports[0].port":  "8080",
+				"hostname":       "ctfer.io",
+				"connectionInfo": `http://{{ index .URLs "8080/TCP" }}`,
+			},
+			ExpectedConnectionInfo: "http://ctfer.io:32544",
+		},
+		"default-connection-info": {
+			Additionals: map[string]string{
+				"image":         "pandatix/license-lvl1:latest",
+				"ports[0].port": "8080",
+				"hostname":      "ctfer.io",
+			},
+			ExpectedConnectionInfo: "8080/TCP: ctfer.io:32544\n",
+		},
+		"nc-helper": {
+			Additionals: map[string]string{
+				"image":          "pandatix/license-lvl1:latest",
+				"ports[0].port":  "1337",
+				"hostname":       "ctfer.io",
+				"connectionInfo": `{{ ncCmd (index .URLs "1337/TCP") }}`,
+			},
+			ExpectedConnectionInfo: "nc ctfer.io 31337",
+		},
+		"ssh-helper": {
+			Additionals: map[string]string{
+				"image":          "pandatix/license-lvl1:latest",
+				"ports[0].port":  "22",
+				"hostname":       "ctfer.io",
+				"connectionInfo": `{{ sshCmd (index .URLs "22/TCP") "ctfer" }}`,
+			},
+			ExpectedConnectionInfo: "ssh -p 30022 ctfer@ctfer.io",
+		},
+		"ingress-helpers": {
+			Additionals: map[string]string{
+				"image":                  "pandatix/license-lvl1:latest",
+				"ports[0].port":          "8080",
+				"ports[0].exposeType":    "Ingress",
+				"hostname":               "ctfer.io",
+				"ingressNamespace":       "networking",
+				"ingressLabels[app]":     "traefik",
+				"connectionInfo":         `{{ $url := index .URLs "8080/TCP" }}{{ httpsURL $url }} {{ openSSLCmd $url }} {{ port $url }}`,
+				"connectionInfoFallback": "none",
+			},
+			ExpectedConnectionInfo: "https://fb4612eb.ctfer.io openssl s_client -connect fb4612eb.ctfer.io:443 -servername fb4612eb.ctfer.io none",
+		},
+		"missing-url-fallback": {
+			Additionals: map[string]string{
+				"image":          "pandatix/license-lvl1:latest",
+				"ports[0].port":  "8080",
+				"hostname":       "ctfer.io",
+				"connectionInfo": `{{ ncCmd (index .URLs "1337/TCP") }}`,
+			},
+			ExpectedConnectionInfo: "unavailable",
+		},
+		"custom-fallback": {
+			Additionals: map[string]string{
+				"image":                  "pandatix/license-lvl1:latest",
+				"ports[0].port":          "8080",
+				"hostname":               "ctfer.io",
+				"connectionInfo":         `{{ httpURL (index .URLs "1337/TCP") }}`,
+				"connectionInfoFallback": "Not deployed yet...",
+			},
+			ExpectedConnectionInfo: "Not deployed yet...",
+		},
+		"structured": {
+			Additionals: map[string]string{
+				"config": `
+image: pandatix/license-lvl1:latest
+ports:
+  - port: 8080
+hostname: ctfer.io
+connectionInfo: 'http://{{ index .URLs "8080/TCP" }}'
+`,
+				"hostname": "overriden.ctfer.io",
+			},
+			ExpectedConnectionInfo: "http://overriden.ctfer.io:32544",
+		},
+		"locked-override": {
+			Additionals: map[string]string{
+				"config": `
+image: pandatix/license-lvl1:latest
+ports:
+  - port: 8080
+hostname: ctfer.io
+`,
+				"image":       "attacker/miner:latest",
+				"limits[cpu]": "64",
+				"hostname":    "overriden.ctfer.io",
+			},
+			ExpectErr:        true,
+			ExpectedErrKind:  recipes.KindValidation,
+			ExpectedErrPaths: []string{"image", "limits[cpu]"},
+		},
+		"negative-user": {
+			Additionals: map[string]string{
+				"image":         "pandatix/license-lvl1:latest",
+				"ports[0].port": "8080",
+				"hostname":      "ctfer.io",
+				"runAsUser":     "-1",
+			},
+			ExpectErr:        true,
+			ExpectedErrKind:  recipes.KindValidation,
+			ExpectedErrPaths: []string{"runAsUser"},
+		},
+		"locked-command-override": {
+			Additionals: map[string]string{
+				"config": `
+image: pandatix/license-lvl1:latest
+command: [/challenge]
+ports:
+  - port: 8080
+hostname: ctfer.io
+`,
+				"command[0]": "/bin/sh",
+				"runAsUser":  "0",
+			},
+			ExpectErr:        true,
+			ExpectedErrKind:  recipes.KindValidation,
+			ExpectedErrPaths: []string{"command[0]", "runAsUser"},
+		},
+		"probe-handlers": {
+			Additionals: map[string]string{
+				"image":                          "pandatix/license-lvl1:latest",
+				"ports[0].port":                  "8080",
+				"hostname":                       "ctfer.io",
+				"readinessProbe.periodSeconds":   "5",
+				"livenessProbe.tcpSocket.port":   "8080",
+				"livenessProbe.exec.command[0]":  "true",
+				"livenessProbe.successThreshold": "2",
+				"startupProbe.httpGet.port":      "8080",
+				"startupProbe.httpGet.scheme":    "FTP",
+			},
+			ExpectErr:       true,
+			ExpectedErrKind: recipes.KindValidation,
+			ExpectedErrPaths: []string{
+				"readinessProbe",
+				"livenessProbe",
+				"livenessProbe.successThreshold",
+				"startupProbe.httpGet.scheme",
+			},
+		},
+		"flags": {
+			Additionals: map[string]string{
+				"image":            "pandatix/license-lvl1:latest",
+				"ports[0].port":    "8080",
+				"hostname":         "ctfer.io",
+				"connectionInfo":   `http://{{ index .URLs "8080/TCP" }}`,
+				"flag.content":     "CTF{some-flag}",
+				"flags[0].content": "CTF{other-flag}",
+			},
+			ExpectedConnectionInfo: "http://ctfer.io:32544",
+			ExpectedFlags:          []string{"CTF{some-flag}", "CTF{other-flag}"},
+		},
+		"variated-flag": {
+			Additionals: map[string]string{
+				"image":          "pandatix/license-lvl1:latest",
+				"ports[0].port":  "8080",
+				"hostname":       "ctfer.io",
+				"connectionInfo": `http://{{ index .URLs "8080/TCP" }}`,
+				"flag.content":   "CTF{some-flag}",
+				"flag.variate":   "true",
+			},
+			ExpectedConnectionInfo: "http://ctfer.io:32544",
+			ExpectedFlags: []string{
+				common.Variable{Content: "CTF{some-flag}", Variate: true}.Produce("a0b1c2d3"),
+			},
+		},
+		"templated-env": {
+			Additionals: map[string]string{
+				"image":                 "pandatix/license-lvl1:latest",
+				"ports[0].port":         "8080",
+				"hostname":              "ctfer.io",
+				"connectionInfo":        `http://{{ index .URLs "8080/TCP" }}`,
+				"flag.content":          "CTF{some-flag}",
+				"envs[FLAG].content":    "{{ .Flag }}",
+				"envs[FLAG].template":   "true",
+				"envs[SERVER].content":  "{{ .Hostname | upper }}",
+				"envs[SERVER].template": "true",
+			},
+			ExpectedConnectionInfo: "http://ctfer.io:32544",
+			ExpectedFlags:          []string{"CTF{some-flag}"},
+			ExpectedEnvs: []any{
+				map[string]any{"name": "FLAG", "value": "CTF{some-flag}"},
+				map[string]any{"name": "SERVER", "value": "CTFER.IO"},
+			},
+		},
+		"literal-env": {
+			Additionals: map[string]string{
+				"image":              "pandatix/license-lvl1:latest",
+				"ports[0].port":      "8080",
+				"hostname":           "ctfer.io",
+				"envs[TMPL].content": "{{ .Flag }}",
+			},
+			ExpectedConnectionInfo: "8080/TCP: ctfer.io:32544\n",
+			ExpectedEnvs: []any{
+				map[string]any{"name": "TMPL", "value": "{{ .Flag }}"},
+			},
+		},
+		"variated-templated-env": {
+			Additionals: map[string]string{
+				"image":               "pandatix/license-lvl1:latest",
+				"ports[0].port":       "8080",
+				"hostname":            "ctfer.io",
+				"flag.content":        "CTF{some-flag}",
+				"envs[FLAG].content":  "secret={{ .Flag }}",
+				"envs[FLAG].template": "true",
+				"envs[FLAG].variate":  "true",
+			},
+			ExpectedConnectionInfo: "8080/TCP: ctfer.io:32544\n",
+			ExpectedFlags:          []string{"CTF{some-flag}"},
+			ExpectedEnvs: []any{
+				map[string]any{"name": "FLAG", "value": common.Variable{Content: "secret=", Variate: true}.Produce("a0b1c2d3") + "CTF{some-flag}"},
+			},
+		},
+		"invalid-env-template": {
+			Additionals: map[string]string{
+				"image":               "pandatix/license-lvl1:latest",
+				"ports[0].port":       "8080",
+				"hostname":            "ctfer.io",
+				"envs[FLAG].content":  "{{ .Unknown }}",
+				"envs[FLAG].template": "true",
+			},
+			ExpectErr:        true,
+			ExpectedErrKind:  recipes.KindTemplate,
+			ExpectedErrPaths: []string{"envs[FLAG]"},
+		},
+		"invalid-template": {
+			Additionals: map[string]string{
+				"image":          "pandatix/license-lvl1:latest",
+				"ports[0].port":  "8080",
+				"hostname":       "ctfer.io",
+				"connectionInfo": `{{ .URLs`,
+			},
+			ExpectErr:        true,
+			ExpectedErrKind:  recipes.KindTemplate,
+			ExpectedErrPaths: []string{"connectionInfo"},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := dryrun.Run(Factory, "a0b1c2d3", tt.Additionals)
+			if tt.ExpectErr {
+				require.Error(t, err)
+				if tt.ExpectedErrKind != "" {
+					var rerr recipes.Error
+					require.ErrorAs(t, err, &rerr)
+					assert.Equal(t, tt.ExpectedErrKind, rerr.Kind())
+					assert.Equal(t, recipes.FaultAuthor, rerr.Fault())
+					assert.Equal(t, dryrun.Project, rerr.RecipeName())
+					assert.ElementsMatch(t, tt.ExpectedErrPaths, rerr.Paths())
+				}
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.ExpectedConnectionInfo, res.ConnectionInfo)
+			assert.ElementsMatch(t, tt.ExpectedFlags, res.Flags)
+			deps := res.Find("kubernetes:apps/v1:Deployment")
+			require.Len(t, deps, 1)
+			if tt.ExpectedEnvs != nil {
+				assert.ElementsMatch(t, tt.ExpectedEnvs, deps[0].Get("spec", "template", "spec", "containers", 0, "env"))
+			}
+		})
+	}
+}
+
+func Test_U_Middleware(t *testing.T) {
+	t.Parallel()
+
+	additionals := map[string]string{
+		"image":         "pandatix/license-lvl1:latest",
+		"ports[0].port": "8080",
+		"hostname":      "ctfer.io",
+	}
+
+	t.Run("modify-request", func(t *testing.T) {
+		t.Parallel()
+
+		res, err := dryrun.Run(Factory, "a0b1c2d3", additionals,
+			recipes.WithMiddleware(func(next recipes.Factory[config.Config]) recipes.Factory[config.Config] {
+				return func(req *recipes.Request[config.Config], resp *sdk.Response, opts ...pulumi.ResourceOption) error {
+					req.Config.Hostname = "middleware.ctfer.io"
+					return next(req, resp, opts...)
+				}
+			}),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "8080/TCP: middleware.ctfer.io:32544\n", res.ConnectionInfo)
+	})
+
+	t.Run("recover-panic", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := dryrun.Run(Factory, "a0b1c2d3", additionals,
+			recipes.WithMiddleware(func(next recipes.Factory[config.Config]) recipes.Factory[config.Config] {
+				return func(req *recipes.Request[config.Config], resp *sdk.Response, opts ...pulumi.ResourceOption) error {
+					panic("oops")
+				}
+			}),
+		)
+		var perr *recipes.ProvisioningError
+		require.ErrorAs(t, err, &perr)
+		assert.Equal(t, recipes.FaultPlatform, perr.Fault())
+	})
+}
+
+func Test_U_IgnoreOverrides(t *testing.T) {
+	t.Parallel()
+
+	res, err := dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"config": `
+image: pandatix/license-lvl1:latest
+ports:
+  - port: 8080
+hostname: ctfer.io
+`,
+		"image":       "attacker/miner:latest",
+		"limits[cpu]": "64",
+	}, recipes.WithOverridePolicy[config.Config](recipes.OverrideIgnore))
+	require.NoError(t, err)
+
+	deps := res.Find("kubernetes:apps/v1:Deployment")
+	require.Len(t, deps, 1)
+	assert.Equal(t, "pandatix/license-lvl1:latest", deps[0].Get("spec", "template", "spec", "containers", 0, "image"))
+	assert.Equal(t, "500m", deps[0].Get("spec", "template", "spec", "containers", 0, "resources", "limits", "cpu"))
+}
+
+func Test_U_Secrets(t *testing.T) {
+	t.Parallel()
+
+	res, err := dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"image":                 "pandatix/license-lvl1:latest",
+		"ports[0].port":         "8080",
+		"hostname":              "ctfer.io",
+		"envs[FLAG].content":    "{{ .Flag }}",
+		"envs[FLAG].template":   "true",
+		"envs[FLAG].secret":     "true",
+		"files[/flag].content":  "{{ .Flag }}",
+		"files[/flag].template": "true",
+		"files[/flag].secret":   "true",
+		"files[/motd].content":  "Welcome!",
+		"flag.content":          "CTF{some-flag}",
+	})
+	require.NoError(t, err)
+
+	deps := res.Find("kubernetes:apps/v1:Deployment")
+	require.Len(t, deps, 1)
+	assert.True(t, deps[0].IsSecret("spec", "template", "spec", "containers", 0, "env"))
+	assert.Equal(t, "CTF{some-flag}", deps[0].Get("spec", "template", "spec", "containers", 0, "env", 0, "value"))
+
+	cms := res.Find("kubernetes:core/v1:ConfigMap")
+	require.Len(t, cms, 1)
+	assert.True(t, cms[0].IsSecret("data"))
+}
+
+func Test_U_SecretFlags(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Flags          map[string]string
+		ExpectedSecret bool
+	}{
+		"plain": {
+			Flags: map[string]string{
+				"flag.content":     "CTF{some-flag}",
+				"flags[0].content": "CTF{other-flag}",
+			},
+			ExpectedSecret: false,
+		},
+		"secret-flag": {
+			Flags: map[string]string{
+				"flag.content":     "CTF{some-flag}",
+				"flag.secret":      "true",
+				"flags[0].content": "CTF{other-flag}",
+			},
+			ExpectedSecret: true,
+		},
+		"secret-flags": {
+			Flags: map[string]string{
+				"flag.content":     "CTF{some-flag}",
+				"flags[0].content": "CTF{other-flag}",
+				"flags[0].secret":  "true",
+			},
+			ExpectedSecret: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			additionals := map[string]string{
+				"image":                 "pandatix/license-lvl1:latest",
+				"ports[0].port":         "8080",
+				"hostname":              "ctfer.io",
+				"envs[FLAG].content":    "{{ .Flag }}",
+				"envs[FLAG].template":   "true",
+				"files[/flag].content":  "{{ index .Flags 1 }}",
+				"files[/flag].template": "true",
+				"connectionInfo":        "{{ .Hostname }} ({{ .Flag }})",
+			}
+			maps.Copy(additionals, tt.Flags)
+
+			res, err := dryrun.Run(Factory, "a0b1c2d3", additionals)
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{"CTF{some-flag}", "CTF{other-flag}"}, res.Flags)
+			assert.Equal(t, tt.ExpectedSecret, res.FlagSecret)
+			assert.Equal(t, tt.ExpectedSecret, res.FlagsSecret)
+
+			// Values rendered out of secret flags are secret too
+			assert.Equal(t, "ctfer.io (CTF{some-flag})", res.ConnectionInfo)
+			assert.Equal(t, tt.ExpectedSecret, res.ConnectionInfoSecret)
+			deps := res.Find("kubernetes:apps/v1:Deployment")
+			require.Len(t, deps, 1)
+			assert.Equal(t, tt.ExpectedSecret, deps[0].IsSecret("spec", "template", "spec", "containers", 0, "env"))
+			cms := res.Find("kubernetes:core/v1:ConfigMap")
+			require.Len(t, cms, 1)
+			assert.Equal(t, tt.ExpectedSecret, cms[0].IsSecret("data"))
+		})
+	}
+}
+
+func Test_U_Runtime(t *testing.T) {
+	t.Parallel()
+
+	res, err := dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"image":         "ctferio/generic:latest",
+		"command[0]":    "python3",
+		"args[0]":       "server.py",
+		"args[1]":       "--level=2",
+		"workingDir":    "/challenge",
+		"runAsUser":     "1000",
+		"runAsGroup":    "1000",
+		"ports[0].port": "8080",
+		"hostname":      "ctfer.io",
+	})
+	require.NoError(t, err)
+
+	deps := res.Find("kubernetes:apps/v1:Deployment")
+	require.Len(t, deps, 1)
+	ctr := []any{"spec", "template", "spec", "containers", 0}
+	assert.Equal(t, []any{"python3"}, deps[0].Get(append(ctr, "command")...))
+	assert.Equal(t, []any{"server.py", "--level=2"}, deps[0].Get(append(ctr, "args")...))
+	assert.Equal(t, "/challenge", deps[0].Get(append(ctr, "workingDir")...))
+	assert.Equal(t, float64(1000), deps[0].Get(append(ctr, "securityContext", "runAsUser")...))
+	assert.Equal(t, float64(1000), deps[0].Get(append(ctr, "securityContext", "runAsGroup")...))
+	assert.Equal(t, "ctferio/generic:latest", deps[0].Get(append(ctr, "image")...))
+}
+
+func Test_U_Probes(t *testing.T) {
+	t.Parallel()
+
+	res, err := dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"image":                                  "pandatix/license-lvl1:latest",
+		"ports[0].port":                          "8080",
+		"hostname":                               "ctfer.io",
+		"readinessProbe.httpGet.port":            "8080",
+		"readinessProbe.httpGet.headers[Host]":   "ctfer.io",
+		"readinessProbe.httpGet.headers[Accept]": "text/plain",
+		"readinessProbe.initialDelaySeconds":     "5",
+		"livenessProbe.tcpSocket.port":           "8080",
+		"livenessProbe.failureThreshold":         "5",
+		"startupProbe.exec.command[0]":           "cat",
+		"startupProbe.exec.command[1]":           "/tmp/ready",
+	})
+	require.NoError(t, err)
+
+	deps := res.Find("kubernetes:apps/v1:Deployment")
+	require.Len(t, deps, 1)
+	ctr := []any{"spec", "template", "spec", "containers", 0}
+	assert.Equal(t, map[string]any{
+		"httpGet": map[string]any{
+			"path":   "/",
+			"port":   float64(8080),
+			"scheme": "HTTP",
+			"httpHeaders": []any{
+				map[string]any{"name": "Accept", "value": "text/plain"},
+				map[string]any{"name": "Host", "value": "ctfer.io"},
+			},
+		},
+		"initialDelaySeconds": float64(5),
+	}, deps[0].Get(append(ctr, "readinessProbe")...))
+	assert.Equal(t, map[string]any{
+		"tcpSocket": map[string]any{
+			"port": float64(8080),
+		},
+		"failureThreshold": float64(5),
+	}, deps[0].Get(append(ctr, "livenessProbe")...))
+	assert.Equal(t, []any{"cat", "/tmp/ready"}, deps[0].Get(append(ctr, "startupProbe", "exec", "command")...))
+}
+
+func Test_U_InitContainers(t *testing.T) {
+	t.Parallel()
+
+	res, err := dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"config": `
+image: postgres:latest
+ports:
+  - port: 5432
+    exposeType: NodePort
+initContainers:
+  - image: busybox:latest
+    command: [sh, -c, 'echo "$FLAG" > /seed/flag']
+    envs:
+      FLAG:
+        content: '{{ .Flag }}'
+        template: true
+        secret: true
+    files:
+      /seed/init.sql:
+        content: CREATE TABLE flags (flag TEXT);
+  - image: busybox:latest
+    command: [chmod, -R, "0400", /seed]
+  - image: envoyproxy/envoy:latest
+    sidecar: true
+hostname: ctfer.io
+flag:
+  content: CTF{seeded}
+`,
+	})
+	require.NoError(t, err)
+
+	deps := res.Find("kubernetes:apps/v1:Deployment")
+	require.Len(t, deps, 1)
+	inits, ok := deps[0].Get("spec", "template", "spec", "initContainers").([]any)
+	require.True(t, ok)
+	require.Len(t, inits, 3)
+
+	// Run in order, before the container
+	seed := inits[0].(map[string]any)
+	assert.Equal(t, "init-0", seed["name"])
+	assert.Equal(t, "busybox:latest", seed["image"])
+	assert.Equal(t, []any{map[string]any{"name": "FLAG", "value": "CTF{seeded}"}}, seed["env"])
+	assert.True(t, deps[0].IsSecret("spec", "template", "spec", "initContainers", 0, "env", 0, "value"))
+	assert.Equal(t, "/seed/init.sql", seed["volumeMounts"].([]any)[0].(map[string]any)["mountPath"])
+	assert.Equal(t, "100m", seed["resources"].(map[string]any)["requests"].(map[string]any)["cpu"])
+
+	chmod := inits[1].(map[string]any)
+	assert.Equal(t, "init-1", chmod["name"])
+	assert.Equal(t, []any{"chmod", "-R", "0400", "/seed"}, chmod["command"])
+	assert.NotContains(t, chmod, "restartPolicy")
+
+	// Keep running alongside the container
+	sidecar := inits[2].(map[string]any)
+	assert.Equal(t, "init-2", sidecar["name"])
+	assert.Equal(t, "Always", sidecar["restartPolicy"])
+
+	cfgs := res.Find("kubernetes:core/v1:ConfigMap")
+	var files *dryrun.Resource
+	for _, cfg := range cfgs {
+		if cfg.Name == "e1p-init" {
+			files = &cfg
+		}
+	}
+	require.NotNil(t, files)
+	assert.Equal(t, "CREATE TABLE flags (flag TEXT);", files.Get("data", "init-0-0"))
+}
+
+// Test_U_MinProfile does not run in parallel, as it sets the minimum
+// security profile through the environment.
+func Test_U_MinProfile(t *testing.T) {
+	var tests = map[string]struct {
+		MinProfile  string
+		Additionals map[string]string
+		ExpectErr   bool
+		Expected    map[string]any
+	}{
+		"none": {
+			Additionals: map[string]string{
+				"security.profile":             "privileged",
+				"security.privileged":          "true",
+				"security.capabilities.add[0]": "SYS_ADMIN",
+			},
+			Expected: map[string]any{
+				"privileged":   true,
+				"capabilities": map[string]any{"add": []any{"SYS_ADMIN"}},
+			},
+		},
+		"baseline": {
+			MinProfile: "baseline",
+			Additionals: map[string]string{
+				"security.profile":             "privileged",
+				"security.privileged":          "true",
+				"security.seccompProfile":      "Unconfined",
+				"security.capabilities.add[0]": "SYS_ADMIN",
+				"security.capabilities.add[1]": "NET_BIND_SERVICE",
+			},
+			Expected: map[string]any{
+				"privileged":     false,
+				"seccompProfile": map[string]any{"type": "RuntimeDefault"},
+				"capabilities":   map[string]any{"add": []any{"NET_BIND_SERVICE"}},
+			},
+		},
+		"restricted": {
+			MinProfile: "restricted",
+			Additionals: map[string]string{
+				"security.allowPrivilegeEscalation": "true",
+				"security.runAsNonRoot":             "false",
+				"security.capabilities.add[0]":      "CHOWN",
+				"security.capabilities.drop[0]":     "NET_RAW",
+			},
+			Expected: map[string]any{
+				"privileged":               false,
+				"allowPrivilegeEscalation": false,
+				"runAsNonRoot":             true,
+				"seccompProfile":           map[string]any{"type": "RuntimeDefault"},
+				"capabilities":             map[string]any{"drop": []any{"ALL"}},
+			},
+		},
+		"invalid": {
+			MinProfile: "paranoid",
+			ExpectErr:  true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Setenv(common.MinProfileEnv, tt.MinProfile)
+
+			additionals := map[string]string{
+				"image":         "ctferio/pwn:latest",
+				"ports[0].port": "1337",
+				"hostname":      "ctfer.io",
+			}
+			maps.Copy(additionals, tt.Additionals)
+			res, err := dryrun.Run(Factory, "a0b1c2d3", additionals)
+			if tt.ExpectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			deps := res.Find("kubernetes:apps/v1:Deployment")
+			require.Len(t, deps, 1)
+			assert.Equal(t, tt.Expected, deps[0].Get("spec", "template", "spec", "containers", 0, "securityContext"))
+		})
+	}
+}
+
+func Test_U_Placement(t *testing.T) {
+	t.Parallel()
+
+	res, err := dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"image":                            "ctferio/kernel-pwn:latest",
+		"ports[0].port":                    "1337",
+		"hostname":                         "ctfer.io",
+		"nodeSelector[pool]":               "pwn",
+		"tolerations[0].key":               "dedicated",
+		"tolerations[0].value":             "pwn",
+		"tolerations[0].effect":            "NoSchedule",
+		"tolerations[1].operator":          "Exists",
+		"tolerations[1].effect":            "NoExecute",
+		"tolerations[1].tolerationSeconds": "60",
+		"spread":                           "true",
+	})
+	require.NoError(t, err)
+
+	deps := res.Find("kubernetes:apps/v1:Deployment")
+	require.Len(t, deps, 1)
+	pod := []any{"spec", "template", "spec"}
+	assert.Equal(t, map[string]any{"pool": "pwn"}, deps[0].Get(append(pod, "nodeSelector")...))
+	assert.Equal(t, "Equal", deps[0].Get(append(pod, "tolerations", 0, "operator")...))
+	assert.Equal(t, "dedicated", deps[0].Get(append(pod, "tolerations", 0, "key")...))
+	assert.Equal(t, "Exists", deps[0].Get(append(pod, "tolerations", 1, "operator")...))
+	assert.Equal(t, float64(60), deps[0].Get(append(pod, "tolerations", 1, "tolerationSeconds")...))
+
+	term := append(pod, "affinity", "podAntiAffinity", "preferredDuringSchedulingIgnoredDuringExecution", 0, "podAffinityTerm")
+	assert.Equal(t, "kubernetes.io/hostname", deps[0].Get(append(term, "topologyKey")...))
+	assert.Equal(t, "one", deps[0].Get(append(term, "labelSelector", "matchLabels", "app.kubernetes.io/name")...))
+
+	// Only the instances of the same source are spread
+	src := deps[0].Get("spec", "template", "metadata", "labels", common.SourceLabel)
+	assert.NotEmpty(t, src)
+	assert.Equal(t, src, deps[0].Get(append(term, "labelSelector", "matchLabels", common.SourceLabel)...))
+	other, err := dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"image":         "ctferio/other-pwn:latest",
+		"ports[0].port": "1337",
+		"hostname":      "ctfer.io",
+		"spread":        "true",
+	})
+	require.NoError(t, err)
+	odeps := other.Find("kubernetes:apps/v1:Deployment")
+	require.Len(t, odeps, 1)
+	assert.NotEqual(t, src, odeps[0].Get("spec", "template", "metadata", "labels", common.SourceLabel))
+
+	// Placement is decided by the challenge author
+	_, err = dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"config": `
+image: ctferio/kernel-pwn:latest
+ports:
+  - port: 1337
+hostname: ctfer.io
+nodeSelector:
+  pool: pwn
+`,
+		"nodeSelector[pool]": "web",
+	})
+	require.Error(t, err)
+}
+
+func Test_U_Registry(t *testing.T) {
+	t.Parallel()
+
+	additionals := map[string]string{
+		"image":               "registry.ctfer.io/challenges/license-lvl1:latest",
+		"ports[0].port":       "8080",
+		"hostname":            "ctfer.io",
+		"imagePullSecrets[0]": "ctfer-registry",
+		"registry.server":     "registry.ctfer.io",
+		"registry.username":   "ctfer",
+		"registry.password":   "s3cr3t",
+	}
+	res, err := dryrun.Run(Factory, "a0b1c2d3", additionals)
+	require.NoError(t, err)
+
+	// The password is redacted from logs, whether decoded or raw
+	conf, err := recipes.Decode[config.Config](additionals)
+	require.NoError(t, err)
+	redacted := recipes.Redact(conf).(map[string]any)
+	assert.Equal(t, map[string]any{
+		"server":   "registry.ctfer.io",
+		"username": "ctfer",
+		"password": recipes.Redacted,
+	}, redacted["registry"])
+	raw := recipes.RedactAdditionals(additionals, reflect.TypeFor[config.Config]())
+	assert.Equal(t, recipes.Redacted, raw["registry.password"])
+
+	secrets := res.Find("kubernetes:core/v1:Secret")
+	require.Len(t, secrets, 1)
+	secret := secrets[0]
+	assert.Equal(t, "kubernetes.io/dockerconfigjson", secret.Get("type"))
+	assert.True(t, secret.IsSecret("stringData", ".dockerconfigjson"))
+
+	var cfg struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(secret.Get("stringData", ".dockerconfigjson").(string)), &cfg))
+	require.Contains(t, cfg.Auths, "registry.ctfer.io")
+	assert.Equal(t, "ctfer", cfg.Auths["registry.ctfer.io"].Username)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("ctfer:s3cr3t")), cfg.Auths["registry.ctfer.io"].Auth)
+
+	// Existing secrets first, then the instance one
+	deps := res.Find("kubernetes:apps/v1:Deployment")
+	require.Len(t, deps, 1)
+	assert.Equal(t, []any{
+		map[string]any{"name": "ctfer-registry"},
+		map[string]any{"name": secret.Get("metadata", "name")},
+	}, deps[0].Get("spec", "template", "spec", "imagePullSecrets"))
+}
@@ -1,52 +1,334 @@
 package config
 
 import (
-	common "github.com/ctfer-io/recipes/chall-manager/common"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/multierr"
 
 	k8s "github.com/ctfer-io/chall-manager/sdk/kubernetes"
+	"github.com/ctfer-io/recipes"
+	common "github.com/ctfer-io/recipes/chall-manager/common"
 )
 
+// Config combines all possibile inputs to this recipe.
 type Config struct {
 	// Inputs
 
-	Containers       map[string]ContainerArgs `form:"containers"              json:"containers"`
-	Rules            []RuleArgs               `form:"rules"                   json:"rules"`
-	Hostname         string                   `form:"hostname"                json:"hostname"`
-	FromCIDR         string                   `form:"fromCidr"                json:"fromCidr"`
-	IngressNamespace string                   `form:"ingressNamespace"        json:"ingressNamespace"`
-	IngressLabels    map[string]string        `form:"ingressLabels,omitempty" json:"ingressLabels,omitempty"`
+	// The containers to deploy, identified by their name.
+	Containers map[string]ContainerArgs `form:"containers" json:"containers" validate:"required,min=1,dive"`
+
+	// The network rules granting interactions between containers.
+	Rules []RuleArgs `form:"rules" json:"rules" validate:"dive"`
+
+	// How to pull the images of all containers from private registries.
+	common.RegistryArgs
+
+	// The volumes the containers could mount, identified by their name.
+	Volumes map[string]VolumeArgs `form:"volumes" json:"volumes,omitempty" validate:"dive" override:"locked"`
+
+	// The hostname to use as part of URLs in the connection info.
+	Hostname string `form:"hostname" json:"hostname" validate:"required"`
+
+	// A CIDR from which to restrict access to the challenge.
+	FromCIDR string `form:"fromCidr" json:"fromCidr" validate:"omitempty,cidr"`
+
+	// The namespace of the ingress controller to grant network access from.
+	// Required if any port uses `exposeType=Ingress`.
+	IngressNamespace string `form:"ingressNamespace" json:"ingressNamespace"`
+
+	// The labels of the ingress controller pods to grant network access from.
+	// Required if any port uses `exposeType=Ingress`.
+	IngressLabels map[string]string `form:"ingressLabels,omitempty" json:"ingressLabels,omitempty"`
+
+	// On which nodes the pods of all containers are scheduled.
+	common.PlacementArgs
 
 	// Outputs
 
+	// The Go template of the connection info to return for each instance,
+	// rendered with the instance values completed by `.URLs` (e.g.
+	// `http://{{ index .URLs "app" "8080/TCP" }}` for the container "app"
+	// that listens on port 8080 over TCP). It supports the sprig and helper
+	// functions. Defaults to listing all URLs of all containers, one per line.
 	ConnectionInfo string `form:"connectionInfo" json:"connectionInfo"`
+
+	// The output format of the connection info, defining how values are
+	// escaped: `text` (as is), `markdown` (as is, with the `mdEscape` function
+	// to escape Markdown special characters) or `html` (escaped according to
+	// the HTML context).
+	ConnectionInfoFormat common.Format `form:"connectionInfoFormat" json:"connectionInfoFormat" validate:"omitempty,oneof=text markdown html" default:"text"`
+
+	// The text the connection info helper functions (e.g. `ncCmd`) return
+	// when the URL they are given is missing (e.g. the port is not exposed).
+	ConnectionInfoFallback string `form:"connectionInfoFallback" json:"connectionInfoFallback"`
+
+	// The flags to return for each instance.
+	common.FlagsArgs
+}
+
+// DefaultConnectionInfo lists all URLs exposed by all containers, one per line.
+const DefaultConnectionInfo = `{{ range $name, $urls := .URLs -}}
+{{ range $port, $url := $urls -}}
+{{ $name }} {{ $port }}: {{ $url }}
+{{ end -}}
+{{ end -}}`
+
+// Defaults sets the values that could not be expressed as struct tags.
+func (conf *Config) Defaults() {
+	if conf.ConnectionInfo == "" {
+		conf.ConnectionInfo = DefaultConnectionInfo
+	}
+	if conf.ConnectionInfoFallback == "" {
+		conf.ConnectionInfoFallback = common.DefaultFallback
+	}
+}
+
+// Validate checks the constraints that span over multiple fields.
+func (conf Config) Validate() (merr error) {
+	// Go through containers in a stable order, for errors to be reproducible
+	ingress := ""
+	for _, name := range slices.Sorted(maps.Keys(conf.Containers)) {
+		ctr := conf.Containers[name]
+		merr = multierr.Append(merr, ctr.ProbesArgs.Check(fmt.Sprintf("containers[%s]", name)))
+		for i, port := range ctr.Ports {
+			if port.ExposeType == k8s.ExposeIngress && ingress == "" {
+				ingress = fmt.Sprintf("containers[%s].ports[%d]", name, i)
+			}
+		}
+	}
+	if ingress != "" {
+		if conf.IngressNamespace == "" {
+			merr = multierr.Append(merr, &recipes.FieldError{
+				Path: "ingressNamespace",
+				Rule: fmt.Sprintf("required as %s is exposed through an Ingress", ingress),
+			})
+		}
+		if len(conf.IngressLabels) == 0 {
+			merr = multierr.Append(merr, &recipes.FieldError{
+				Path: "ingressLabels",
+				Rule: fmt.Sprintf("required as %s is exposed through an Ingress", ingress),
+			})
+		}
+	}
+
+	merr = multierr.Append(merr, conf.validateVolumes())
+
+	for i, rule := range conf.Rules {
+		if _, ok := conf.Containers[rule.From]; rule.From != "" && !ok {
+			merr = multierr.Append(merr, &recipes.FieldError{
+				Path: fmt.Sprintf("rules[%d].from", i),
+				Rule: fmt.Sprintf("container %s not found", rule.From),
+			})
+		}
+		if _, ok := conf.Containers[rule.To]; rule.To != "" && !ok {
+			merr = multierr.Append(merr, &recipes.FieldError{
+				Path: fmt.Sprintf("rules[%d].to", i),
+				Rule: fmt.Sprintf("container %s not found", rule.To),
+			})
+		}
+	}
+	return
+}
+
+// volumeName is a Kubernetes volume name (RFC 1123 label).
+var volumeName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// validateVolumes checks the volumes could be mounted as configured.
+func (conf Config) validateVolumes() (merr error) {
+	for _, name := range slices.Sorted(maps.Keys(conf.Volumes)) {
+		if len(name) > 63 || !volumeName.MatchString(name) {
+			merr = multierr.Append(merr, &recipes.FieldError{
+				Path: fmt.Sprintf("volumes[%s]", name),
+				Rule: "name must be a lowercase RFC 1123 label",
+			})
+		}
+	}
+
+	// Init containers run in the pod of their container
+	mountedBy := map[string][]string{}
+	mount := func(name, path string, mounts []MountArgs) {
+		for i, mount := range mounts {
+			if _, ok := conf.Volumes[mount.Volume]; !ok {
+				merr = multierr.Append(merr, &recipes.FieldError{
+					Path: fmt.Sprintf("%s.mounts[%d].volume", path, i),
+					Rule: fmt.Sprintf("volume %s not found", mount.Volume),
+				})
+				continue
+			}
+			if !slices.Contains(mountedBy[mount.Volume], name) {
+				mountedBy[mount.Volume] = append(mountedBy[mount.Volume], name)
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(conf.Containers)) {
+		ctr := conf.Containers[name]
+		mount(name, fmt.Sprintf("containers[%s]", name), ctr.Mounts)
+		for i, init := range ctr.InitContainers {
+			mount(name, fmt.Sprintf("containers[%s].initContainers[%d]", name, i), init.Mounts)
+		}
+	}
+
+	// Containers run in distinct pods, possibly on distinct nodes
+	for _, vol := range slices.Sorted(maps.Keys(mountedBy)) {
+		ctrs := mountedBy[vol]
+		if len(ctrs) < 2 {
+			continue
+		}
+		switch args := conf.Volumes[vol]; {
+		case !args.Persistent:
+			merr = multierr.Append(merr, &recipes.FieldError{
+				Path: fmt.Sprintf("volumes[%s].persistent", vol),
+				Rule: fmt.Sprintf("required as it is shared by %s, which run in distinct pods", strings.Join(ctrs, ", ")),
+			})
+		case args.AccessMode != "ReadWriteMany":
+			merr = multierr.Append(merr, &recipes.FieldError{
+				Path: fmt.Sprintf("volumes[%s].accessMode", vol),
+				Rule: fmt.Sprintf("ReadWriteMany required as it is shared by %s, which run in distinct pods", strings.Join(ctrs, ", ")),
+			})
+		}
+	}
+	return
 }
 
+// ContainerArgs defines a container to deploy.
 type ContainerArgs struct {
-	Image    string                     `form:"image"       json:"image"`
-	Ports    []common.PortArgs          `form:"ports"       json:"ports"`
-	Envs     map[string]Printable       `form:"envs"        json:"envs"`
-	Files    map[string]common.Variable `form:"files"       json:"files"`
-	Requests map[string]string          `form:"requests"    json:"requests"`
-	Limits   map[string]string          `form:"limits"      json:"limits"`
+	// The Docker image reference to deploy.
+	Image string `form:"image" json:"image" validate:"required" override:"locked"`
+
+	// How the container runs its image (e.g. its command).
+	common.RuntimeArgs
+
+	// How Kubernetes checks the health of the container.
+	common.ProbesArgs
+
+	// How the container is hardened, with a security profile (e.g.
+	// `security.profile=restricted`) and explicit overrides of its settings.
+	// It applies to the init containers too.
+	Security common.SecurityArgs `form:"security" json:"security" override:"locked"`
+
+	// The ports, protocols and expose types of the container.
+	Ports []common.PortArgs `form:"ports" json:"ports" validate:"dive"`
+
+	// The environment variables to pass to the container, either as a
+	// variable or as a format of other containers services.
+	Envs map[string]Printable `form:"envs" json:"envs"`
+
+	// The files to mount in the container, identified by their absolute path.
+	// Their contents could be Go templates rendered with the instance values.
+	Files map[string]common.Variable `form:"files" json:"files" validate:"omitempty,dive,keys,startswith=/,endkeys"`
+
+	// The volumes to mount in the container.
+	Mounts []MountArgs `form:"mounts" json:"mounts,omitempty" validate:"dive"`
+
+	// The containers to run to completion, in order, before the container
+	// starts (e.g. to seed a database with the flag).
+	InitContainers []InitContainerArgs `form:"initContainers" json:"initContainers,omitempty" validate:"dive"`
+
+	// The resource requests of the container.
+	Requests map[string]string `form:"requests" json:"requests" default:"cpu=100m,memory=128Mi" override:"locked"`
+
+	// The resource limits of the container.
+	Limits map[string]string `form:"limits" json:"limits" default:"cpu=500m,memory=256Mi" override:"locked"`
 }
 
+// InitContainerArgs defines a container that runs to completion before
+// the one of its pod starts.
+type InitContainerArgs struct {
+	common.InitContainerArgs
+
+	// The volumes to mount in the init container (e.g. to prepare the files
+	// of its pod container).
+	Mounts []MountArgs `form:"mounts" json:"mounts,omitempty" validate:"dive"`
+}
+
+// RuleArgs grants network interaction from a container to another.
 type RuleArgs struct {
-	From     string `form:"from"     json:"from"`
-	To       string `form:"to"       json:"to"`
-	On       int    `form:"on"       json:"on"`
-	Protocol string `form:"protocol" json:"protocol"`
+	// The container name from which to grant network interaction.
+	From string `form:"from" json:"from" validate:"required"`
+
+	// The container name to which grant network interaction.
+	To string `form:"to" json:"to" validate:"required"`
+
+	// The port on which to grant network interaction.
+	On int `form:"on" json:"on" validate:"required,min=1,max=65535"`
+
+	// The protocol on which to grant network interaction.
+	Protocol string `form:"protocol" json:"protocol" validate:"omitempty,oneof=TCP UDP SCTP" default:"TCP"`
 }
 
+// VolumeArgs defines a volume containers could mount.
+//
+// As each container runs in its own pod, an emptyDir volume could not be
+// shared between containers: it requires a persistent volume, with the
+// `ReadWriteMany` access mode.
+type VolumeArgs struct {
+	// The size of the volume (e.g. `1Gi`), as the size limit of an emptyDir
+	// or the storage requested for a persistent volume.
+	// Required for a persistent volume.
+	Size string `form:"size" json:"size,omitempty" validate:"required_if=Persistent true"`
+
+	// Whether to back the volume by a PersistentVolumeClaim, rather than an
+	// emptyDir that lives as long as the pod.
+	Persistent bool `form:"persistent" json:"persistent,omitempty"`
+
+	// The storage class of the persistent volume. Defaults to the cluster
+	// default one.
+	StorageClass string `form:"storageClass" json:"storageClass,omitempty"`
+
+	// The access mode of the persistent volume, `ReadWriteMany` to mount it
+	// in multiple containers.
+	AccessMode string `form:"accessMode" json:"accessMode,omitempty" validate:"omitempty,oneof=ReadWriteOnce ReadWriteMany" default:"ReadWriteOnce"`
+}
+
+// MountArgs mounts a volume in a container.
+type MountArgs struct {
+	// The name of the volume to mount.
+	Volume string `form:"volume" json:"volume" validate:"required"`
+
+	// The absolute path to mount the volume at.
+	Path string `form:"path" json:"path" validate:"required,startswith=/"`
+
+	// The path within the volume to mount, rather than its root.
+	SubPath string `form:"subPath" json:"subPath,omitempty"`
+
+	// Whether to mount the volume read-only.
+	ReadOnly bool `form:"readOnly" json:"readOnly,omitempty"`
+}
+
+// Printable is an environment variable content, either a [common.Variable]
+// or a format referencing other containers services.
 type Printable struct {
+	// The content of the environment variable, possibly a Go template
+	// rendered with the instance values (e.g. `{{ .Flag }}`).
+	// Takes precedence over the format.
 	Variable common.Variable `form:"variable" json:"variable"`
 
-	Format   string   `form:"format"   json:"format"`
+	// The format of the environment variable, with `%s` placeholders
+	// replaced by the services URLs.
+	Format string `form:"format" json:"format"`
+
+	// The services, as `<container>:<port>`, to format the environment
+	// variable with.
 	Serivces []string `form:"services" json:"services"`
 }
 
-func (pr Printable) ToPrinter(seed string) k8s.PrinterArgs {
+// ToPrinter renders the variable content with the values (see
+// [common.Variable.Render]) if defined, else uses the format and services.
+func (pr Printable) ToPrinter(seed string, values *common.Values) (k8s.PrinterArgs, error) {
 	if pr.Variable.Content != "" {
-		return k8s.NewPrinter(pr.Variable.Produce(seed))
+		content, err := pr.Variable.Render(seed, values)
+		if err != nil {
+			return k8s.PrinterArgs{}, err
+		}
+		return k8s.PrinterArgs{
+			Fmt:      pr.Variable.ToInput(content, values),
+			Services: pulumi.StringArray{},
+		}, nil
 	}
-	return k8s.NewPrinter(pr.Format, pr.Serivces...)
+	return k8s.NewPrinter(pr.Format, pr.Serivces...), nil
 }
@@ -0,0 +1,142 @@
+// Package recipe deploys a Kubernetes ExposedMultipod from the recipe configuration.
+package recipe
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"github.com/ctfer-io/chall-manager/sdk"
+	k8s "github.com/ctfer-io/chall-manager/sdk/kubernetes"
+	"github.com/ctfer-io/recipes"
+	"github.com/ctfer-io/recipes/chall-manager/common"
+	"github.com/ctfer-io/recipes/chall-manager/k8s.EMP/config"
+)
+
+// Factory deploys the Kubernetes ExposedMultipod.
+func Factory(req *recipes.Request[config.Config], resp *sdk.Response, opts ...pulumi.ResourceOption) error {
+	// Build template ASAP -> fail fast
+	citmpl, err := common.NewTemplate("connectionInfo", req.Config.ConnectionInfo, req.Config.ConnectionInfoFormat,
+		common.WithFallback(req.Config.ConnectionInfoFallback),
+	)
+	if err != nil {
+		return &recipes.TemplateError{Path: "connectionInfo", Err: err}
+	}
+
+	// Create the persistent volumes, before the containers mounting them
+	claims, err := newClaims(req, opts...)
+	if err != nil {
+		return err
+	}
+
+	// Pull images from private registries
+	pullSecrets, err := common.ImagePullSecrets(req.Ctx, "emp-registry", req.Config.RegistryArgs, req.Identity, opts...)
+	if err != nil {
+		return err
+	}
+
+	// Harden containers, at least as much as the platform operator requires
+	minProfile, err := common.MinProfile()
+	if err != nil {
+		return err
+	}
+
+	// Build containers, with envs and files rendered with the instance values
+	values := common.NewValues(req.Identity, req.Ctx.Stack(), req.Config.Hostname, req.Flags, req.SecretFlags)
+	containers := k8s.ContainerMap{}
+	patches := map[string]common.PodPatch{}
+	for name, args := range req.Config.Containers {
+		envs := k8s.PrinterMap{}
+		for k, v := range args.Envs {
+			pr, err := v.ToPrinter(req.Identity, values)
+			if err != nil {
+				return &recipes.TemplateError{Path: fmt.Sprintf("containers[%s].envs[%s]", name, k), Err: err}
+			}
+			envs[k] = pr
+		}
+		files := pulumi.StringMap{}
+		for path, f := range args.Files {
+			content, err := f.Render(req.Identity, values)
+			if err != nil {
+				return &recipes.TemplateError{Path: fmt.Sprintf("containers[%s].files[%s]", name, path), Err: err}
+			}
+			files[path] = f.ToInput(content, values)
+		}
+
+		inits := make([]common.InitContainerArgs, 0, len(args.InitContainers))
+		for _, init := range args.InitContainers {
+			inits = append(inits, init.InitContainerArgs)
+		}
+		initsPatch, err := common.InitContainers(req.Ctx, "emp-init-"+name, fmt.Sprintf("containers[%s].initContainers", name), inits, req.Identity, values, opts...)
+		if err != nil {
+			return err
+		}
+
+		containers[name] = k8s.ContainerArgs{
+			Image: pulumi.String(args.Image),
+			Ports: func() k8s.PortBindingArray {
+				out := make([]k8s.PortBindingInput, 0, len(args.Ports))
+				for _, port := range args.Ports {
+					out = append(out, k8s.PortBindingArgs{
+						Port:        pulumi.Int(port.Port),
+						Protocol:    pulumi.String(port.Protocol),
+						ExposeType:  port.ExposeType,
+						Annotations: pulumi.ToStringMap(port.Annotations),
+					})
+				}
+				return out
+			}(),
+			Envs:     envs,
+			Files:    files,
+			Requests: pulumi.ToStringMap(args.Requests),
+			Limits:   pulumi.ToStringMap(args.Limits),
+		}
+		security, warns := args.Security.Resolve(fmt.Sprintf("containers[%s].security", name), minProfile)
+		for _, warn := range warns {
+			_ = req.Ctx.Log.Warn(warn, nil)
+		}
+		patches[name] = common.Patches(
+			args.RuntimeArgs.Patch,
+			args.ProbesArgs.Patch,
+			initsPatch, // before mounting volumes in the init containers
+			pullSecrets,
+			mountsPatch(args, req.Config.Volumes, claims),
+			req.Config.PlacementArgs.Patch(name),
+			security.Patch, // after the runtime and init containers patches
+		)
+	}
+
+	// Set what the SDK does not expose
+	opts = append(opts, common.PatchPods(patches))
+
+	// Deploy k8s.ExposedMultipod
+	cm, err := k8s.NewExposedMultipod(req.Ctx, "recipe-k8s-emp", &k8s.ExposedMultipodArgs{
+		Identity:   pulumi.String(req.Identity),
+		Label:      pulumi.String(req.Ctx.Stack()),
+		Hostname:   pulumi.String(req.Config.Hostname),
+		Containers: containers,
+		Rules: func() k8s.RuleArray {
+			out := []k8s.RuleInput{}
+			for _, rule := range req.Config.Rules {
+				out = append(out, k8s.RuleArgs{
+					From:     pulumi.String(rule.From),
+					To:       pulumi.String(rule.To),
+					On:       pulumi.Int(rule.On),
+					Protocol: pulumi.String(rule.Protocol),
+				})
+			}
+			return out
+		}(),
+		FromCIDR:         pulumi.String(req.Config.FromCIDR),
+		IngressNamespace: pulumi.String(req.Config.IngressNamespace),
+		IngressLabels:    pulumi.ToStringMap(req.Config.IngressLabels),
+	}, opts...)
+	if err != nil {
+		return err
+	}
+
+	// Template connection info
+	resp.ConnectionInfo = common.ConnectionInfo[map[string]map[string]string](citmpl, values, cm.URLs)
+
+	return nil
+}
@@ -0,0 +1,577 @@
+package recipe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ctfer-io/recipes"
+	"github.com/ctfer-io/recipes/chall-manager/k8s.EMP/config"
+	"github.com/ctfer-io/recipes/dryrun"
+)
+
+func Test_U_Factory(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Additionals            map[string]string
+		ExpectErr              bool
+		ExpectedConnectionInfo string
+		ExpectedDeployments    int
+	}{
+		"empty": {
+			Additionals: map[string]string{},
+			ExpectErr:   true,
+		},
+		"missing-image": {
+			Additionals: map[string]string{
+				"containers[app].ports[0].port":       "8080",
+				"containers[app].ports[0].exposeType": "NodePort",
+				"hostname":                            "ctfer.io",
+			},
+			ExpectErr: true,
+		},
+		"rule-to-unknown-container": {
+			Additionals: map[string]string{
+				"containers[app].image":               "nginx:latest",
+				"containers[app].ports[0].port":       "8080",
+				"containers[app].ports[0].exposeType": "NodePort",
+				"rules[0].from":                       "app",
+				"rules[0].to":                         "db",
+				"rules[0].on":                         "5432",
+				"hostname":                            "ctfer.io",
+			},
+			ExpectErr: true,
+		},
+		"basic": {
+			Additionals: map[string]string{
+				"containers[app].image":               "nginx:latest",
+				"containers[app].ports[0].port":       "8080",
+				"containers[app].ports[0].exposeType": "NodePort",
+				"hostname":                            "ctfer.io",
+				"connectionInfo":                      `http://{{ index .URLs "app" "8080/TCP" }}`,
+			},
+			ExpectedConnectionInfo: "http://ctfer.io:32544",
+			ExpectedDeployments:    1,
+		},
+		"multiple-containers": {
+			Additionals: map[string]string{
+				"config": `
+containers:
+  app:
+    image: nginx:latest
+    ports:
+      - port: 8080
+        exposeType: NodePort
+    envs:
+      DB:
+        format: postgres://%s
+        services: [db]
+  db:
+    image: postgres:latest
+    ports:
+      - port: 5432
+rules:
+  - from: app
+    to: db
+    on: 5432
+hostname: ctfer.io
+`,
+			},
+			ExpectedConnectionInfo: "app 8080/TCP: ctfer.io:32544\n",
+			ExpectedDeployments:    2,
+		},
+		"text-format": {
+			Additionals: map[string]string{
+				"containers[app].image":               "nginx:latest",
+				"containers[app].ports[0].port":       "8080",
+				"containers[app].ports[0].exposeType": "NodePort",
+				"hostname":                            "ctfer.io",
+				"flag.content":                        "CTF{<some>&flag}",
+				"connectionInfo":                      `http://{{ index .URLs "app" "8080/TCP" }}/?id={{ .Identity }}&flag={{ .Flag }}`,
+			},
+			ExpectedConnectionInfo: "http://ctfer.io:32544/?id=a0b1c2d3&flag=CTF{<some>&flag}",
+			ExpectedDeployments:    1,
+		},
+		"html-format": {
+			Additionals: map[string]string{
+				"containers[app].image":               "nginx:latest",
+				"containers[app].ports[0].port":       "8080",
+				"containers[app].ports[0].exposeType": "NodePort",
+				"hostname":                            "ctfer.io",
+				"flag.content":                        "CTF{<some>&flag}",
+				"connectionInfo":                      `<p>{{ .Flag }}</p>`,
+				"connectionInfoFormat":                "html",
+			},
+			ExpectedConnectionInfo: "<p>CTF{&lt;some&gt;&amp;flag}</p>",
+			ExpectedDeployments:    1,
+		},
+		"markdown-format": {
+			Additionals: map[string]string{
+				"containers[app].image":               "nginx:latest",
+				"containers[app].ports[0].port":       "8080",
+				"containers[app].ports[0].exposeType": "NodePort",
+				"hostname":                            "ctfer.io",
+				"flag.content":                        "CTF{some_flag}",
+				"connectionInfo":                      `**Flag**: {{ mdEscape .Flag }}`,
+				"connectionInfoFormat":                "markdown",
+			},
+			ExpectedConnectionInfo: `**Flag**: CTF\{some\_flag\}`,
+			ExpectedDeployments:    1,
+		},
+		"unsupported-format": {
+			Additionals: map[string]string{
+				"containers[app].image": "nginx:latest",
+				"hostname":              "ctfer.io",
+				"connectionInfoFormat":  "pdf",
+			},
+			ExpectErr: true,
+		},
+		"locked-override": {
+			Additionals: map[string]string{
+				"config": `
+containers:
+  app:
+    image: nginx:latest
+hostname: ctfer.io
+`,
+				"containers[app].image": "attacker/miner:latest",
+			},
+			ExpectErr: true,
+		},
+		"probe-without-handler": {
+			Additionals: map[string]string{
+				"containers[app].image":                        "nginx:latest",
+				"containers[app].ports[0].port":                "8080",
+				"containers[app].ports[0].exposeType":          "NodePort",
+				"containers[app].readinessProbe.periodSeconds": "5",
+				"hostname": "ctfer.io",
+			},
+			ExpectErr: true,
+		},
+		"readiness-probe": {
+			Additionals: map[string]string{
+				"containers[app].image":                       "nginx:latest",
+				"containers[app].ports[0].port":               "8080",
+				"containers[app].ports[0].exposeType":         "NodePort",
+				"containers[app].readinessProbe.httpGet.port": "8080",
+				"containers[app].readinessProbe.httpGet.path": "/healthz",
+				"hostname":       "ctfer.io",
+				"connectionInfo": `http://{{ index .URLs "app" "8080/TCP" }}`,
+			},
+			ExpectedConnectionInfo: "http://ctfer.io:32544",
+			ExpectedDeployments:    1,
+		},
+		"mount-unknown-volume": {
+			Additionals: map[string]string{
+				"containers[app].image":            "nginx:latest",
+				"containers[app].mounts[0].volume": "data",
+				"containers[app].mounts[0].path":   "/data",
+				"hostname":                         "ctfer.io",
+			},
+			ExpectErr: true,
+		},
+		"invalid-volume-name": {
+			Additionals: map[string]string{
+				"containers[app].image":            "nginx:latest",
+				"containers[app].mounts[0].volume": "Data_1",
+				"containers[app].mounts[0].path":   "/data",
+				"volumes[Data_1].size":             "1Gi",
+				"hostname":                         "ctfer.io",
+			},
+			ExpectErr: true,
+		},
+		"persistent-without-size": {
+			Additionals: map[string]string{
+				"containers[app].image":            "nginx:latest",
+				"containers[app].mounts[0].volume": "data",
+				"containers[app].mounts[0].path":   "/data",
+				"volumes[data].persistent":         "true",
+				"hostname":                         "ctfer.io",
+			},
+			ExpectErr: true,
+		},
+		"shared-emptydir": {
+			Additionals: map[string]string{
+				"containers[app].image":               "nginx:latest",
+				"containers[app].mounts[0].volume":    "data",
+				"containers[app].mounts[0].path":      "/data",
+				"containers[worker].image":            "busybox:latest",
+				"containers[worker].mounts[0].volume": "data",
+				"containers[worker].mounts[0].path":   "/data",
+				"volumes[data].size":                  "1Gi",
+				"hostname":                            "ctfer.io",
+			},
+			ExpectErr: true,
+		},
+		"shared-read-write-once": {
+			Additionals: map[string]string{
+				"containers[app].image":               "nginx:latest",
+				"containers[app].mounts[0].volume":    "data",
+				"containers[app].mounts[0].path":      "/data",
+				"containers[worker].image":            "busybox:latest",
+				"containers[worker].mounts[0].volume": "data",
+				"containers[worker].mounts[0].path":   "/data",
+				"volumes[data].size":                  "1Gi",
+				"volumes[data].persistent":            "true",
+				"hostname":                            "ctfer.io",
+			},
+			ExpectErr: true,
+		},
+		"toleration-value-with-exists": {
+			Additionals: map[string]string{
+				"containers[app].image":   "nginx:latest",
+				"tolerations[0].key":      "dedicated",
+				"tolerations[0].operator": "Exists",
+				"tolerations[0].value":    "web",
+				"hostname":                "ctfer.io",
+			},
+			ExpectErr: true,
+		},
+		"placement": {
+			Additionals: map[string]string{
+				"containers[app].image":               "nginx:latest",
+				"containers[app].ports[0].port":       "8080",
+				"containers[app].ports[0].exposeType": "NodePort",
+				"containers[db].image":                "postgres:latest",
+				"nodeSelector[pool]":                  "web",
+				"tolerations[0].key":                  "dedicated",
+				"tolerations[0].value":                "web",
+				"spread":                              "true",
+				"hostname":                            "ctfer.io",
+				"connectionInfo":                      `http://{{ index .URLs "app" "8080/TCP" }}`,
+			},
+			ExpectedConnectionInfo: "http://ctfer.io:32544",
+			ExpectedDeployments:    2,
+		},
+		"registry-without-password": {
+			Additionals: map[string]string{
+				"containers[app].image": "registry.ctfer.io/app:latest",
+				"registry.server":       "registry.ctfer.io",
+				"registry.username":     "ctfer",
+				"hostname":              "ctfer.io",
+			},
+			ExpectErr: true,
+		},
+		"private-registry": {
+			Additionals: map[string]string{
+				"containers[app].image":               "registry.ctfer.io/app:latest",
+				"containers[app].ports[0].port":       "8080",
+				"containers[app].ports[0].exposeType": "NodePort",
+				"containers[db].image":                "registry.ctfer.io/db:latest",
+				"imagePullSecrets[0]":                 "ctfer-registry",
+				"registry.server":                     "registry.ctfer.io",
+				"registry.username":                   "ctfer",
+				"registry.password":                   "s3cr3t",
+				"hostname":                            "ctfer.io",
+				"connectionInfo":                      `http://{{ index .URLs "app" "8080/TCP" }}`,
+			},
+			ExpectedConnectionInfo: "http://ctfer.io:32544",
+			ExpectedDeployments:    2,
+		},
+		"template-execution-error": {
+			Additionals: map[string]string{
+				"containers[app].image":               "nginx:latest",
+				"containers[app].ports[0].port":       "8080",
+				"containers[app].ports[0].exposeType": "NodePort",
+				"hostname":                            "ctfer.io",
+				"connectionInfo":                      `{{ .Unknown }}`,
+			},
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := dryrun.Run(Factory, "a0b1c2d3", tt.Additionals)
+			if tt.ExpectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.ExpectedConnectionInfo, res.ConnectionInfo)
+			assert.Len(t, res.Find("kubernetes:apps/v1:Deployment"), tt.ExpectedDeployments)
+		})
+	}
+}
+
+func Test_U_Runtime(t *testing.T) {
+	t.Parallel()
+
+	res, err := dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"config": `
+containers:
+  app:
+    image: ctferio/generic:latest
+    command: [python3, server.py]
+    workingDir: /challenge
+    runAsUser: 1000
+    ports:
+      - port: 8080
+        exposeType: NodePort
+  db:
+    image: postgres:latest
+hostname: ctfer.io
+`,
+	})
+	require.NoError(t, err)
+
+	deps := map[string]dryrun.Resource{}
+	for _, dep := range res.Find("kubernetes:apps/v1:Deployment") {
+		deps[dep.Name] = dep
+	}
+	require.Len(t, deps, 2)
+
+	ctr := []any{"spec", "template", "spec", "containers", 0}
+	app := deps["emp-dep-app"]
+	assert.Equal(t, []any{"python3", "server.py"}, app.Get(append(ctr, "command")...))
+	assert.Equal(t, "/challenge", app.Get(append(ctr, "workingDir")...))
+	assert.Equal(t, float64(1000), app.Get(append(ctr, "securityContext", "runAsUser")...))
+
+	// Others are left untouched
+	db := deps["emp-dep-db"]
+	assert.Nil(t, db.Get(append(ctr, "command")...))
+	assert.Nil(t, db.Get(append(ctr, "securityContext", "runAsUser")...))
+}
+
+func Test_U_Volumes(t *testing.T) {
+	t.Parallel()
+
+	res, err := dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"config": `
+containers:
+  app:
+    image: nginx:latest
+    ports:
+      - port: 8080
+        exposeType: NodePort
+    files:
+      /etc/flag:
+        content: CTF{flag}
+    mounts:
+      - volume: uploads
+        path: /srv/uploads
+      - volume: scratch
+        path: /tmp
+  worker:
+    image: busybox:latest
+    mounts:
+      - volume: uploads
+        path: /uploads
+        readOnly: true
+volumes:
+  uploads:
+    persistent: true
+    size: 1Gi
+    storageClass: nfs
+    accessMode: ReadWriteMany
+  scratch:
+    size: 64Mi
+hostname: ctfer.io
+`,
+	})
+	require.NoError(t, err)
+
+	pvcs := res.Find("kubernetes:core/v1:PersistentVolumeClaim")
+	require.Len(t, pvcs, 1)
+	pvc := pvcs[0]
+	assert.Equal(t, []any{"ReadWriteMany"}, pvc.Get("spec", "accessModes"))
+	assert.Equal(t, "nfs", pvc.Get("spec", "storageClassName"))
+	assert.Equal(t, "1Gi", pvc.Get("spec", "resources", "requests", "storage"))
+	claim := pvc.Get("metadata", "name")
+	require.NotEmpty(t, claim)
+
+	deps := map[string]dryrun.Resource{}
+	for _, dep := range res.Find("kubernetes:apps/v1:Deployment") {
+		deps[dep.Name] = dep
+	}
+	require.Len(t, deps, 2)
+
+	// Volumes are appended to the files ones
+	pod := []any{"spec", "template", "spec"}
+	app := deps["emp-dep-app"]
+	vols := map[any]any{}
+	for _, v := range app.Get(append(pod, "volumes")...).([]any) {
+		vols[v.(map[string]any)["name"]] = v
+	}
+	require.Len(t, vols, 3)
+	assert.Equal(t, claim, vols["uploads"].(map[string]any)["persistentVolumeClaim"].(map[string]any)["claimName"])
+	assert.Equal(t, "64Mi", vols["scratch"].(map[string]any)["emptyDir"].(map[string]any)["sizeLimit"])
+
+	mounts := app.Get(append(pod, "containers", 0, "volumeMounts")...).([]any)
+	require.Len(t, mounts, 3)
+	assert.Equal(t, "/srv/uploads", mounts[1].(map[string]any)["mountPath"])
+	assert.Equal(t, "/tmp", mounts[2].(map[string]any)["mountPath"])
+
+	// The worker shares the persistent volume only
+	worker := deps["emp-dep-worker"]
+	assert.Equal(t, []any{map[string]any{
+		"name": "uploads",
+		"persistentVolumeClaim": map[string]any{
+			"claimName": claim,
+		},
+	}}, worker.Get(append(pod, "volumes")...))
+	assert.Equal(t, []any{map[string]any{
+		"name":      "uploads",
+		"mountPath": "/uploads",
+		"readOnly":  true,
+	}}, worker.Get(append(pod, "containers", 0, "volumeMounts")...))
+}
+
+func Test_U_InitContainers(t *testing.T) {
+	t.Parallel()
+
+	res, err := dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"config": `
+containers:
+  app:
+    image: nginx:latest
+    ports:
+      - port: 8080
+        exposeType: NodePort
+    mounts:
+      - volume: www
+        path: /usr/share/nginx/html
+        readOnly: true
+    initContainers:
+      - image: busybox:latest
+        command: [sh, -c, 'cp /seed/index.html /www/ && chmod 0444 /www/index.html']
+        files:
+          /seed/index.html:
+            content: '<p>{{ .Flag }}</p>'
+            template: true
+        mounts:
+          - volume: www
+            path: /www
+  db:
+    image: postgres:latest
+volumes:
+  www:
+    size: 16Mi
+hostname: ctfer.io
+flag:
+  content: CTF{seeded}
+`,
+	})
+	require.NoError(t, err)
+
+	deps := map[string]dryrun.Resource{}
+	for _, dep := range res.Find("kubernetes:apps/v1:Deployment") {
+		deps[dep.Name] = dep
+	}
+	require.Len(t, deps, 2)
+
+	pod := []any{"spec", "template", "spec"}
+	app := deps["emp-dep-app"]
+	inits, ok := app.Get(append(pod, "initContainers")...).([]any)
+	require.True(t, ok)
+	require.Len(t, inits, 1)
+	init := inits[0].(map[string]any)
+	assert.Equal(t, "init-0", init["name"])
+	mounts := init["volumeMounts"].([]any)
+	require.Len(t, mounts, 2)
+	assert.Equal(t, "/seed/index.html", mounts[0].(map[string]any)["mountPath"])
+	assert.Equal(t, map[string]any{"name": "www", "mountPath": "/www", "readOnly": false}, mounts[1])
+
+	// The volume is shared with the container, added once to the pod
+	www := 0
+	for _, v := range app.Get(append(pod, "volumes")...).([]any) {
+		if v.(map[string]any)["name"] == "www" {
+			www++
+		}
+	}
+	assert.Equal(t, 1, www)
+
+	// Others are left untouched
+	assert.Nil(t, deps["emp-dep-db"].Get(append(pod, "initContainers")...))
+
+	cfgs := map[string]dryrun.Resource{}
+	for _, cfg := range res.Find("kubernetes:core/v1:ConfigMap") {
+		cfgs[cfg.Name] = cfg
+	}
+	require.Contains(t, cfgs, "emp-init-app")
+	assert.Equal(t, "<p>CTF{seeded}</p>", cfgs["emp-init-app"].Get("data", "init-0-0"))
+}
+
+func Test_U_Security(t *testing.T) {
+	t.Parallel()
+
+	res, err := dryrun.Run(Factory, "a0b1c2d3", map[string]string{
+		"config": `
+containers:
+  app:
+    image: ctferio/pwn:latest
+    runAsUser: 1000
+    security:
+      profile: restricted
+      readOnlyRootFilesystem: true
+    ports:
+      - port: 1337
+        exposeType: NodePort
+    initContainers:
+      - image: busybox:latest
+  sandbox:
+    image: ctferio/sandbox:latest
+    security:
+      profile: privileged
+      capabilities:
+        add: [SYS_ADMIN]
+hostname: ctfer.io
+`,
+	})
+	require.NoError(t, err)
+
+	deps := map[string]dryrun.Resource{}
+	for _, dep := range res.Find("kubernetes:apps/v1:Deployment") {
+		deps[dep.Name] = dep
+	}
+	require.Len(t, deps, 2)
+
+	// Completes the runtime security context, and applies to init containers
+	pod := []any{"spec", "template", "spec"}
+	app := deps["emp-dep-app"]
+	for _, ctr := range [][]any{
+		append(pod, "containers", 0),
+		append(pod, "initContainers", 0),
+	} {
+		sc := append(ctr, "securityContext")
+		assert.Equal(t, false, app.Get(append(sc, "privileged")...))
+		assert.Equal(t, false, app.Get(append(sc, "allowPrivilegeEscalation")...))
+		assert.Equal(t, true, app.Get(append(sc, "runAsNonRoot")...))
+		assert.Equal(t, true, app.Get(append(sc, "readOnlyRootFilesystem")...))
+		assert.Equal(t, "RuntimeDefault", app.Get(append(sc, "seccompProfile", "type")...))
+		assert.Equal(t, []any{"ALL"}, app.Get(append(sc, "capabilities", "drop")...))
+	}
+	assert.Equal(t, float64(1000), app.Get(append(pod, "containers", 0, "securityContext", "runAsUser")...))
+
+	sandbox := deps["emp-dep-sandbox"]
+	sc := append(pod, "containers", 0, "securityContext")
+	assert.Nil(t, sandbox.Get(append(sc, "privileged")...))
+	assert.Equal(t, []any{"SYS_ADMIN"}, sandbox.Get(append(sc, "capabilities", "add")...))
+}
+
+func Test_U_ValidateIngress(t *testing.T) {
+	t.Parallel()
+
+	additionals := map[string]string{
+		"containers[b].image":               "nginx:latest",
+		"containers[b].ports[0].port":       "80",
+		"containers[b].ports[0].exposeType": "Ingress",
+		"containers[a].image":               "nginx:latest",
+		"containers[a].ports[0].port":       "8080",
+		"containers[a].ports[0].exposeType": "Ingress",
+		"hostname":                          "ctfer.io",
+	}
+
+	// The first container exposed through an Ingress is reported, whatever
+	// the map iteration order
+	for range 10 {
+		_, err := recipes.Decode[config.Config](additionals)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ingressNamespace: required as containers[a].ports[0] is exposed through an Ingress")
+	}
+}
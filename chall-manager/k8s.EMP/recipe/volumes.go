@@ -0,0 +1,118 @@
+package recipe
+
+import (
+	"maps"
+	"slices"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"github.com/ctfer-io/recipes"
+	"github.com/ctfer-io/recipes/chall-manager/common"
+	"github.com/ctfer-io/recipes/chall-manager/k8s.EMP/config"
+)
+
+// newClaims creates the PersistentVolumeClaims of the persistent volumes,
+// and returns their names by volume.
+func newClaims(req *recipes.Request[config.Config], opts ...pulumi.ResourceOption) (map[string]pulumi.StringOutput, error) {
+	claims := map[string]pulumi.StringOutput{}
+	for _, name := range slices.Sorted(maps.Keys(req.Config.Volumes)) {
+		vol := req.Config.Volumes[name]
+		if !vol.Persistent {
+			continue
+		}
+
+		var storageClass pulumi.StringPtrInput
+		if vol.StorageClass != "" {
+			storageClass = pulumi.String(vol.StorageClass)
+		}
+		pvc, err := corev1.NewPersistentVolumeClaim(req.Ctx, "recipe-k8s-emp-pvc-"+name, &corev1.PersistentVolumeClaimArgs{
+			Metadata: metav1.ObjectMetaArgs{
+				Name: pulumi.Sprintf("emp-pvc-%s-%s-%s", req.Ctx.Stack(), req.Identity, name),
+				Labels: pulumi.StringMap{
+					"chall-manager.ctfer.io/identity": pulumi.String(req.Identity),
+				},
+			},
+			Spec: corev1.PersistentVolumeClaimSpecArgs{
+				AccessModes:      pulumi.ToStringArray([]string{vol.AccessMode}),
+				StorageClassName: storageClass,
+				Resources: corev1.VolumeResourceRequirementsArgs{
+					Requests: pulumi.StringMap{
+						"storage": pulumi.String(vol.Size),
+					},
+				},
+			},
+		}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		claims[name] = pvc.Metadata.Name().Elem()
+	}
+	return claims, nil
+}
+
+// mountsPatch mounts the volumes in a container and its init containers,
+// as a [common.PodPatch]. It must run after the init containers are added.
+func mountsPatch(args config.ContainerArgs, volumes map[string]config.VolumeArgs, claims map[string]pulumi.StringOutput) common.PodPatch {
+	return func(pod *corev1.PodSpecArgs, ctr *corev1.ContainerArgs) {
+		// Volumes are added once to the pod, whatever the containers mounting
+		// them (e.g. sub-paths, or an init container preparing it)
+		mounted := []string{}
+		mount := func(ctr *corev1.ContainerArgs, mounts []config.MountArgs) {
+			if len(mounts) == 0 {
+				return
+			}
+			vms := []corev1.VolumeMountInput{}
+			for _, mount := range mounts {
+				var subPath pulumi.StringPtrInput
+				if mount.SubPath != "" {
+					subPath = pulumi.String(mount.SubPath)
+				}
+				vms = append(vms, corev1.VolumeMountArgs{
+					Name:      pulumi.String(mount.Volume),
+					MountPath: pulumi.String(mount.Path),
+					SubPath:   subPath,
+					ReadOnly:  pulumi.Bool(mount.ReadOnly),
+				})
+				if !slices.Contains(mounted, mount.Volume) {
+					mounted = append(mounted, mount.Volume)
+				}
+			}
+			common.AppendVolumeMounts(ctr, vms...)
+		}
+		mount(ctr, args.Mounts)
+		if inits, ok := pod.InitContainers.(corev1.ContainerArray); ok {
+			for i, init := range args.InitContainers {
+				ictr := inits[i].(corev1.ContainerArgs)
+				mount(&ictr, init.Mounts)
+				inits[i] = ictr
+			}
+		}
+
+		if len(mounted) == 0 {
+			return
+		}
+		vs := []corev1.VolumeInput{}
+		for _, name := range mounted {
+			v := corev1.VolumeArgs{
+				Name: pulumi.String(name),
+			}
+			if claim, ok := claims[name]; ok {
+				v.PersistentVolumeClaim = corev1.PersistentVolumeClaimVolumeSourceArgs{
+					ClaimName: claim,
+				}
+			} else {
+				var sizeLimit pulumi.StringPtrInput
+				if size := volumes[name].Size; size != "" {
+					sizeLimit = pulumi.String(size)
+				}
+				v.EmptyDir = corev1.EmptyDirVolumeSourceArgs{
+					SizeLimit: sizeLimit,
+				}
+			}
+			vs = append(vs, v)
+		}
+		common.AppendVolumes(pod, vs...)
+	}
+}
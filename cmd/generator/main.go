@@ -15,6 +15,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/ctfer-io/recipes"
+	"github.com/ctfer-io/recipes/internal/names"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
@@ -46,6 +48,7 @@ var (
 	preparedFiles = []string{
 		"main",
 		"Pulumi.yaml",
+		"schema.json",
 	}
 
 	dhClient *DockerHubClient
@@ -98,12 +101,7 @@ func run(ctx context.Context) (err error) {
 			into := filepath.Join(dist, fmt.Sprintf("%s_%s_%s.tar.gz", eco, e.Name(), ver))
 
 			// Transform into a Docker-compliant name
-			sub := e.Name()
-			sub = strings.ToLower(sub)
-			sub = strings.NewReplacer(
-				".", "-",
-			).Replace(sub)
-			dhRepoName := fmt.Sprintf("recipes_%s_%s", eco, sub)
+			dhRepoName := names.Repository(eco, e.Name())
 
 			if err := build(ctx, dir, into, dhRepoName, ver); err != nil {
 				return errors.Wrapf(err, "failed to build %s", dir)
@@ -126,6 +124,11 @@ func build(ctx context.Context, dir, into, dhRepoName, ver string) error {
 		return err
 	}
 
+	// Generate the JSON Schema of the recipe configuration
+	if err := schema(ctx, dir); err != nil {
+		return err
+	}
+
 	// Then pack it all in an OCI layout in filesystem ...
 	if err := ociLayout(ctx, dir, ver); err != nil {
 		return err
@@ -151,6 +154,19 @@ func compile(ctx context.Context, dir string) error {
 	return err
 }
 
+func schema(ctx context.Context, dir string) error {
+	fmt.Println("    Generating schema...")
+	cmd := exec.CommandContext(ctx, "./main")
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("%s=%s", recipes.SchemaEnv, "schema.json"),
+	)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "generating schema: %s", out)
+	}
+	return nil
+}
+
 func ociLayout(ctx context.Context, dir, ver string) error {
 	// Prepare the Pulumi.yaml file with the prebuilt content
 	if err := preparePulumiYaml(dir); err != nil {
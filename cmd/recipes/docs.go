@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ctfer-io/recipes"
+	"github.com/ctfer-io/recipes/chall-manager/common/catalog"
+	"github.com/ctfer-io/recipes/internal/gomod"
+)
+
+// ecosystem of the recipes of the catalog.
+const ecosystem = "chall-manager"
+
+// updateDocs regenerates the inputs and outputs tables of the recipes
+// READMEs from their configuration, in the recipes module of dir.
+// When check is set, it rather fails if any of them is stale.
+func updateDocs(stdout io.Writer, dir string, check bool) error {
+	root, _, err := gomod.Find(dir)
+	if err != nil {
+		return err
+	}
+	if root == "" {
+		return errors.New("docs must run from within the recipes module")
+	}
+	docs, err := recipes.LoadDocs(root)
+	if err != nil {
+		return errors.Wrap(err, "loading docs")
+	}
+
+	stale := []string{}
+	for _, name := range catalog.Names() {
+		r, _ := catalog.Get(name)
+		p := filepath.Join(root, ecosystem, name, "README.md")
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		inputs, outputs, err := tables(root, r.Type(), r.Schema(docs))
+		if err != nil {
+			return errors.Wrapf(err, "rendering %s tables", name)
+		}
+		readme, err := replaceSection(string(b), inputsBegin, inputsEnd, inputs)
+		if err != nil {
+			return errors.Wrap(err, p)
+		}
+		readme, err = replaceSection(readme, outputsBegin, outputsEnd, outputs)
+		if err != nil {
+			return errors.Wrap(err, p)
+		}
+		if readme == string(b) {
+			continue
+		}
+
+		if check {
+			stale = append(stale, p)
+			continue
+		}
+		if err := os.WriteFile(p, []byte(readme), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Updated %s\n", p)
+	}
+	if len(stale) != 0 {
+		return errors.Errorf("stale docs in %s, run `recipes docs` to update them", strings.Join(stale, ", "))
+	}
+	return nil
+}
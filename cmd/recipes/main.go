@@ -0,0 +1,208 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ctfer-io/recipes"
+	"github.com/ctfer-io/recipes/chall-manager/common/catalog"
+)
+
+const usage = `Render and validate recipes configurations offline.
+
+Usage:
+  recipes <command> [flags] <recipe> <file>
+  recipes new <ecosystem>/<name>
+  recipes docs [-check]
+
+Commands:
+  validate  Decode, default and validate the configuration.
+  render    Print the produced flags and the rendered connection info.
+  preview   Print a report of what the recipe would deploy.
+  new       Scaffold a new recipe in the recipes module.
+  docs      Regenerate the inputs and outputs tables of the recipes READMEs.
+
+The file contains the additional values, either as a YAML or JSON document
+(.yaml, .yml or .json), or form-encoded with one key=value per line.
+Use "-" to read it from the standard input (form-encoded).
+
+Recipes:
+  %s
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintf(stdout, usage, strings.Join(catalog.Names(), "\n  "))
+		return errors.New("missing command")
+	}
+
+	cmd := args[0]
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	switch cmd {
+	case "new":
+		if len(args) != 2 {
+			return errors.Errorf("new expects <ecosystem>/<name>, got %d arguments", len(args)-1)
+		}
+		return newRecipe(stdout, ".", args[1])
+
+	case "docs":
+		check := fs.Bool("check", false, "Fail if any README is stale, rather than updating it.")
+		if err := fs.Parse(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil
+			}
+			return err
+		}
+		return updateDocs(stdout, ".", *check)
+	}
+
+	identity := fs.String("identity", "a0b1c2d3", "Identity of the instance, used as the seed of variated values.")
+	format := fs.String("format", "text", "Output format of the preview report, text or json.")
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.Errorf("%s expects a recipe and a file, got %d arguments", cmd, fs.NArg())
+	}
+
+	r, ok := catalog.Get(fs.Arg(0))
+	if !ok {
+		return errors.Errorf("unknown recipe %s, expected one of %s", fs.Arg(0), strings.Join(catalog.Names(), ", "))
+	}
+	additionals, err := load(fs.Arg(1), stdin)
+	if err != nil {
+		return errors.Wrapf(err, "loading %s", fs.Arg(1))
+	}
+
+	switch cmd {
+	case "validate":
+		return validate(stdout, r, additionals)
+	case "render":
+		return render(stdout, r, *identity, additionals)
+	case "preview":
+		return preview(stdout, r, *identity, additionals, *format)
+	}
+	return errors.Errorf("unknown command %s", cmd)
+}
+
+func validate(stdout io.Writer, r catalog.Recipe, additionals map[string]string) error {
+	if _, err := r.Decode(additionals); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "Configuration is valid")
+	return nil
+}
+
+func render(stdout io.Writer, r catalog.Recipe, identity string, additionals map[string]string) error {
+	res, err := r.Preview(identity, additionals)
+	if err != nil {
+		// Prefer the recipe error to the Pulumi one wrapping it
+		var rerr recipes.Error
+		if errors.As(err, &rerr) {
+			return rerr
+		}
+		return err
+	}
+
+	if len(res.Flags) != 0 {
+		fmt.Fprintln(stdout, "Flags:")
+		for _, flag := range res.Flags {
+			fmt.Fprintf(stdout, "- %s\n", flag)
+		}
+	}
+	fmt.Fprintf(stdout, "Connection info:\n%s\n", res.ConnectionInfo)
+	return nil
+}
+
+func preview(stdout io.Writer, r catalog.Recipe, identity string, additionals map[string]string, format string) error {
+	rep := catalog.Preview(r, identity, additionals)
+
+	var out string
+	var err error
+	switch format {
+	case "text":
+		out, err = rep.Text()
+	case "json":
+		out, err = rep.JSON()
+	default:
+		return errors.Errorf("unsupported format %s, expected text or json", format)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, out)
+
+	if len(rep.Errors) != 0 {
+		return errors.New("invalid configuration")
+	}
+	return nil
+}
+
+// load the additional values from the file.
+// The top-level scalar values of a document are additional values, but if
+// any is not, the whole document is given as the recipes.ConfigKey one.
+func load(path string, stdin io.Reader) (map[string]string, error) {
+	var b []byte
+	var err error
+	if path == "-" {
+		b, err = io.ReadAll(stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+		doc := map[string]any{}
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, err
+		}
+		additionals := make(map[string]string, len(doc))
+		for k, v := range doc {
+			switch v.(type) {
+			case map[string]any, []any:
+				return map[string]string{
+					recipes.ConfigKey: string(b),
+				}, nil
+			case nil:
+				additionals[k] = ""
+			default:
+				additionals[k] = fmt.Sprint(v)
+			}
+		}
+		return additionals, nil
+	}
+
+	additionals := map[string]string{}
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, errors.Errorf("line %d: expected key=value", i+1)
+		}
+		additionals[strings.TrimSpace(k)] = v
+	}
+	return additionals, nil
+}
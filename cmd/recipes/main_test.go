@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ctfer-io/recipes/internal/gomod"
+)
+
+func Test_U_Run(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Args      []string
+		Stdin     string
+		ExpectErr bool
+		Contains  []string
+	}{
+		"no-command": {
+			Args:      []string{},
+			ExpectErr: true,
+			Contains:  []string{"k8s.E1P", "k8s.EMP"},
+		},
+		"unknown-recipe": {
+			Args:      []string{"validate", "k8s.Unknown", "testdata/e1p.env"},
+			ExpectErr: true,
+		},
+		"validate-form": {
+			Args:     []string{"validate", "k8s.E1P", "testdata/e1p.env"},
+			Contains: []string{"Configuration is valid"},
+		},
+		"validate-stdin": {
+			Args:     []string{"validate", "k8s.E1P", "-"},
+			Stdin:    "image=pandatix/license-lvl1:latest\nports[0].port=8080\nhostname=ctfer.io\n",
+			Contains: []string{"Configuration is valid"},
+		},
+		"validate-locked-form": {
+			// Locked fields are set as form keys when no document is given
+			Args:     []string{"validate", "k8s.E1P", "-"},
+			Stdin:    "image=pandatix/license-lvl1:latest\ncommand[0]=/challenge\nlimits[cpu]=1\nports[0].port=8080\nhostname=ctfer.io\n",
+			Contains: []string{"Configuration is valid"},
+		},
+		"preview-form": {
+			Args: []string{"preview", "k8s.E1P", "testdata/e1p.env"},
+			Contains: []string{
+				"Recipe: k8s.E1P\n",
+				"- kubernetes:apps/v1:Deployment ",
+			},
+		},
+		"validate-invalid": {
+			Args:      []string{"validate", "k8s.E1P", "testdata/invalid.env"},
+			ExpectErr: true,
+		},
+		"render-document": {
+			Args: []string{"render", "k8s.E1P", "testdata/e1p.yaml"},
+			Contains: []string{
+				"Flags:\n- ",
+				"Connection info:\nnc ctfer.io 31337\n",
+			},
+		},
+		"preview-json": {
+			Args: []string{"preview", "-format", "json", "k8s.E1P", "testdata/e1p.yaml"},
+			Contains: []string{
+				`"recipe": "k8s.E1P"`,
+				`"type": "kubernetes:apps/v1:Deployment"`,
+			},
+		},
+		"docs-check": {
+			// Fails when the READMEs are stale, run `recipes docs` to fix it
+			Args: []string{"docs", "-check"},
+		},
+		"preview-invalid": {
+			Args:      []string{"preview", "k8s.E1P", "testdata/invalid.env"},
+			ExpectErr: true,
+			Contains:  []string{"Errors:\n- "},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			stdout := &bytes.Buffer{}
+			err := run(tt.Args, strings.NewReader(tt.Stdin), stdout)
+			if tt.ExpectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			for _, s := range tt.Contains {
+				assert.Contains(t, stdout.String(), s)
+			}
+		})
+	}
+}
+
+func Test_U_New(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Target    string
+		ExpectErr bool
+	}{
+		"new": {
+			Target: "chall-manager/web.app",
+		},
+		"missing-name": {
+			Target:    "chall-manager",
+			ExpectErr: true,
+		},
+		"invalid-name": {
+			Target:    "chall-manager/web app",
+			ExpectErr: true,
+		},
+		"reserved-name": {
+			Target:    "chall-manager/common",
+			ExpectErr: true,
+		},
+		"unknown-ecosystem": {
+			Target:    "unknown/web",
+			ExpectErr: true,
+		},
+		"normalized-collision": {
+			Target:    "chall-manager/K8S-e1p",
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			// Scaffold in a copy of the recipes module, as from within it
+			root := copyModule(t)
+
+			stdout := &bytes.Buffer{}
+			err := newRecipe(stdout, root, tt.Target)
+			if tt.ExpectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			for _, dst := range skeletonFiles {
+				require.FileExists(t, filepath.Join(root, tt.Target, dst))
+			}
+
+			readme, err := os.ReadFile(filepath.Join(root, tt.Target, "README.md"))
+			require.NoError(t, err)
+			assert.Contains(t, string(readme), "| `image` | **Required**. The Docker image reference to deploy.")
+
+			cat, err := os.ReadFile(filepath.Join(root, "chall-manager", "common", "catalog", "catalog.go"))
+			require.NoError(t, err)
+			assert.Contains(t, string(cat), `New("web.app", webapp.Factory),`)
+
+			// The scaffold and its test compile, and are registered
+			cmd := exec.Command("go", "vet", "./"+tt.Target+"/...", "./chall-manager/common/catalog")
+			cmd.Dir = root
+			out, err := cmd.CombinedOutput()
+			assert.NoError(t, err, string(out))
+		})
+	}
+}
+
+// copyModule copies the sources of the recipes module into a temporary
+// directory, and returns it.
+func copyModule(t *testing.T) string {
+	t.Helper()
+
+	src, _, err := gomod.Find(".")
+	require.NoError(t, err)
+	dst := t.TempDir()
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") && p != src {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), b, 0644)
+	})
+	require.NoError(t, err)
+	return dst
+}
+
+func Test_U_ReplaceSection(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Readme    string
+		Expected  string
+		ExpectErr bool
+	}{
+		"replaced": {
+			Readme:   "# Recipe\n\n" + inputsBegin + "\n| stale |\n" + inputsEnd + "\n\nMore.\n",
+			Expected: "# Recipe\n\n" + inputsBegin + "\n| fresh |\n" + inputsEnd + "\n\nMore.\n",
+		},
+		"missing-markers": {
+			Readme:    "# Recipe\n",
+			ExpectErr: true,
+		},
+		"inverted-markers": {
+			Readme:    inputsEnd + "\n" + inputsBegin,
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			out, err := replaceSection(tt.Readme, inputsBegin, inputsEnd, inputsBegin+"\n| fresh |\n"+inputsEnd)
+			if tt.ExpectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.Expected, out)
+		})
+	}
+}
+
+func Test_U_FormatDefault(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Default  any
+		Expected string
+	}{
+		"scalar": {
+			Default:  "baseline",
+			Expected: "baseline",
+		},
+		"map": {
+			Default:  map[string]string{"memory": "128Mi", "cpu": "100m"},
+			Expected: "cpu=100m,memory=128Mi",
+		},
+		"slice": {
+			Default:  []string{"ALL"},
+			Expected: "ALL",
+		},
+		"struct": {
+			Default:  struct{ Profile string }{Profile: "baseline"},
+			Expected: "",
+		},
+		"struct-pointer": {
+			Default:  &struct{ Profile string }{Profile: "baseline"},
+			Expected: "",
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.Expected, formatDefault(tt.Default))
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/format"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/ctfer-io/recipes"
+	"github.com/ctfer-io/recipes/cmd/recipes/skeleton/config"
+	"github.com/ctfer-io/recipes/internal/gomod"
+	"github.com/ctfer-io/recipes/internal/names"
+)
+
+//go:embed skeleton
+var skeleton embed.FS
+
+// skeletonFiles maps the skeleton files to their path in the new recipe.
+var skeletonFiles = map[string]string{
+	"skeleton/main.go.tmpl":        "main.go",
+	"skeleton/recipe.go.tmpl":      "recipe/recipe.go",
+	"skeleton/recipe_test.go.tmpl": "recipe/recipe_test.go",
+	"skeleton/config/config.go":    "config/config.go",
+	"skeleton/Pulumi.yaml.tmpl":    "Pulumi.yaml",
+	"skeleton/README.md.tmpl":      "README.md",
+}
+
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// newRecipe scaffolds the recipe <ecosystem>/<name> from the skeleton, in
+// the recipes module of dir.
+func newRecipe(stdout io.Writer, dir, target string) error {
+	eco, name, ok := strings.Cut(target, "/")
+	if !ok || eco == "" || name == "" {
+		return errors.Errorf("expected <ecosystem>/<name>, got %s", target)
+	}
+	if !nameRegex.MatchString(name) {
+		return errors.Errorf("invalid recipe name %s, expected to match %s", name, nameRegex)
+	}
+	if name == "common" {
+		return errors.New("common is reserved for shared datastructures and helpers")
+	}
+
+	root, modPath, err := gomod.Find(dir)
+	if err != nil {
+		return err
+	}
+	if root == "" {
+		return errors.New("new must run from within the recipes module")
+	}
+
+	// Recipes are published under their normalized name, which must be
+	// unique in the ecosystem
+	entries, err := os.ReadDir(filepath.Join(root, eco))
+	if err != nil {
+		return errors.Wrapf(err, "reading ecosystem %s", eco)
+	}
+	for _, e := range entries {
+		if e.IsDir() && names.Normalize(e.Name()) == names.Normalize(name) {
+			return errors.Errorf("recipe %s would collide with %s once published as %s", name, e.Name(), names.Repository(eco, name))
+		}
+	}
+
+	// The skeleton configuration sources are part of the recipes module
+	docs, err := recipes.LoadDocs(root)
+	if err != nil {
+		return errors.Wrap(err, "loading docs")
+	}
+	inputs, outputs, err := tables(root, reflect.TypeFor[config.Config](), recipes.SchemaOf[config.Config](docs))
+	if err != nil {
+		return err
+	}
+	data := map[string]any{
+		"Name":    name,
+		"Package": path.Join(modPath, eco, name),
+		"Project": names.Normalize(name),
+		"Inputs":  inputs,
+		"Outputs": outputs,
+	}
+
+	dir = filepath.Join(root, eco, name)
+	for _, src := range slices.Sorted(maps.Keys(skeletonFiles)) {
+		dst := skeletonFiles[src]
+		b, err := skeleton.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(src, ".tmpl") {
+			tmpl, err := template.New(src).Delims("[[", "]]").Parse(string(b))
+			if err != nil {
+				return errors.Wrapf(err, "parsing %s", src)
+			}
+			buf := &bytes.Buffer{}
+			if err := tmpl.Execute(buf, data); err != nil {
+				return errors.Wrapf(err, "executing %s", src)
+			}
+			b = buf.Bytes()
+		}
+
+		p := filepath.Join(dir, dst)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, b, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Created %s\n", p)
+	}
+	return register(stdout, root, data["Package"].(string), eco, name)
+}
+
+// register the recipe in the catalog of its ecosystem, if any (e.g. for
+// the debug recipe and the CLI to know it).
+func register(stdout io.Writer, root, pkg, eco, name string) error {
+	p := filepath.Join(root, eco, "common", "catalog", "catalog.go")
+	b, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	src := string(b)
+
+	// Import the recipe package under an alias made of its name, with
+	// the other imports such that gofmt sorts it
+	alias := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+	if alias == "" || unicode.IsDigit(rune(alias[0])) {
+		alias = "r" + alias
+	}
+	start := strings.Index(src, "import (\n")
+	end := strings.Index(src[max(start, 0):], "\n)\n")
+	if start < 0 || end < 0 {
+		return errors.Errorf("imports not found in %s", p)
+	}
+	end += start
+	src = fmt.Sprintf("%s\n\t%s %q%s", src[:end], alias, pkg+"/recipe", src[end:])
+
+	// Add it to the catalog
+	start = strings.Index(src, "var catalog = []Recipe{\n")
+	end = strings.Index(src[max(start, 0):], "\n}\n")
+	if start < 0 || end < 0 {
+		return errors.Errorf("catalog not found in %s", p)
+	}
+	end += start
+	src = fmt.Sprintf("%s\n\tNew(%q, %s.Factory),%s", src[:end], name, alias, src[end:])
+
+	b, err = format.Source([]byte(src))
+	if err != nil {
+		return errors.Wrapf(err, "formatting %s", p)
+	}
+	if err := os.WriteFile(p, b, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Registered %s in %s\n", name, p)
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ctfer-io/recipes"
+	"github.com/ctfer-io/recipes/internal/gomod"
+)
+
+const (
+	inputsBegin  = "<!-- recipes:inputs:begin -->"
+	inputsEnd    = "<!-- recipes:inputs:end -->"
+	outputsBegin = "<!-- recipes:outputs:begin -->"
+	outputsEnd   = "<!-- recipes:outputs:end -->"
+)
+
+// tables renders the inputs and outputs tables of a recipe configuration
+// type t and its schema s, delimited by markers such that they could be
+// regenerated (see [replaceSection]).
+// Outputs are the fields declared after the `// Outputs` comment of the
+// configuration struct, which sources are looked up in the module of dir.
+func tables(dir string, t reflect.Type, s *recipes.Schema) (inputs, outputs string, err error) {
+	outs, err := outputFields(dir, t)
+	if err != nil {
+		return "", "", err
+	}
+
+	in, out := &strings.Builder{}, &strings.Builder{}
+	for _, b := range []*strings.Builder{in, out} {
+		b.WriteString("| Form Path | Description |\n")
+		b.WriteString("|---|---|\n")
+	}
+	for _, f := range recipes.FormFields(t) {
+		// Promoted fields are outputs if the embedded struct is
+		if outs[t.Field(f.Index[0]).Name] {
+			fieldRows(out, f, s, "")
+		} else {
+			fieldRows(in, f, s, "")
+		}
+	}
+	return inputsBegin + "\n" + in.String() + inputsEnd,
+		outputsBegin + "\n" + out.String() + outputsEnd,
+		nil
+}
+
+// fieldRows writes the row of a struct field, given the schema of its
+// parent, then the rows of its nested fields.
+func fieldRows(b *strings.Builder, f recipes.FormField, parent *recipes.Schema, path string) {
+	sf, name := f.StructField, f.Name
+	s, ok := parent.Properties[name]
+	if !ok {
+		return
+	}
+	desc := s.Description
+	if slices.Contains(parent.Required, name) {
+		desc = "**Required**. " + desc
+	}
+	if path != "" {
+		name = path + "." + name
+	}
+	if s.Default != nil {
+		// Multi-line defaults (e.g. a template) would break the table, so
+		// are left to the description
+		if def := formatDefault(s.Default); def != "" && !strings.Contains(def, "\n") {
+			desc += fmt.Sprintf(" Defaults to `%s`.", def)
+		}
+	}
+	if sf.Tag.Get(recipes.OverrideTag) == "locked" {
+		desc += " Locked (see below)."
+	}
+	desc = strings.ReplaceAll(strings.TrimSpace(desc), "|", "\\|")
+	fmt.Fprintf(b, "| `%s` | %s |\n", name, desc)
+
+	// Go through structures, either directly or as elements
+	t := sf.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct:
+		structRows(b, t, s, name)
+	case t.Kind() == reflect.Map && t.Elem().Kind() == reflect.Struct:
+		structRows(b, t.Elem(), s.AdditionalProperties, name+"[xxx]")
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Struct:
+		structRows(b, t.Elem(), s.Items, name+"[x]")
+	}
+}
+
+func structRows(b *strings.Builder, t reflect.Type, s *recipes.Schema, path string) {
+	for _, f := range recipes.FormFields(t) {
+		fieldRows(b, f, s, path)
+	}
+}
+
+// formatDefault formats a default value the way it is written in the
+// additional values (e.g. `cpu=100m,memory=128Mi` for a map).
+// Objects have no such form, as their fields list their own defaults, so
+// are formatted empty.
+func formatDefault(def any) string {
+	v := reflect.ValueOf(def)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return ""
+
+	case reflect.Map:
+		pairs := []string{}
+		iter := v.MapRange()
+		for iter.Next() {
+			pairs = append(pairs, fmt.Sprintf("%v=%v", iter.Key(), iter.Value()))
+		}
+		slices.Sort(pairs)
+		return strings.Join(pairs, ",")
+
+	case reflect.Slice, reflect.Array:
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elems[i] = fmt.Sprint(v.Index(i))
+		}
+		return strings.Join(elems, ",")
+	}
+	return fmt.Sprint(def)
+}
+
+// outputFields returns the names of the fields of the struct type t that
+// are declared after its `// Outputs` comment, looking for its sources in
+// the module of dir.
+func outputFields(dir string, t reflect.Type) (map[string]bool, error) {
+	root, modPath, err := gomod.Find(dir)
+	if err != nil {
+		return nil, err
+	}
+	rel, ok := strings.CutPrefix(t.PkgPath(), modPath)
+	if root == "" || !ok {
+		return nil, errors.Errorf("sources of %s are not part of the module of %s", t, dir)
+	}
+
+	files, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(rel), "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", file)
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok && ts.Name.Name == t.Name() {
+					return afterOutputs(f, st), nil
+				}
+			}
+		}
+	}
+	return nil, errors.Errorf("sources of %s not found", t)
+}
+
+func afterOutputs(f *ast.File, st *ast.StructType) map[string]bool {
+	marker := token.NoPos
+	for _, cg := range f.Comments {
+		if cg.Pos() > st.Fields.Opening && cg.End() < st.Fields.Closing && strings.TrimSpace(cg.Text()) == "Outputs" {
+			marker = cg.Pos()
+		}
+	}
+
+	outs := map[string]bool{}
+	if !marker.IsValid() {
+		return outs
+	}
+	for _, field := range st.Fields.List {
+		if field.Pos() < marker {
+			continue
+		}
+		for _, name := range field.Names {
+			outs[name.Name] = true
+		}
+		if len(field.Names) != 0 {
+			continue
+		}
+
+		// Embedded fields are named after their type
+		typ := field.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		switch typ := typ.(type) {
+		case *ast.Ident:
+			outs[typ.Name] = true
+		case *ast.SelectorExpr:
+			outs[typ.Sel.Name] = true
+		}
+	}
+	return outs
+}
+
+// replaceSection replaces the content between the begin and end markers
+// (included) of a README by section, which starts and ends with them.
+func replaceSection(readme, begin, end, section string) (string, error) {
+	i := strings.Index(readme, begin)
+	j := strings.Index(readme, end)
+	if i < 0 || j < i {
+		return "", errors.Errorf("missing %s and %s markers", begin, end)
+	}
+	return readme[:i] + section + readme[j+len(end):], nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	common "github.com/ctfer-io/recipes/chall-manager/common"
+)
+
+// Config combines all possibile inputs to this recipe.
+type Config struct {
+	// Inputs
+
+	// The Docker image reference to deploy.
+	Image string `form:"image" json:"image" validate:"required" override:"locked"`
+
+	// The hostname to use as part of URLs in the connection info.
+	Hostname string `form:"hostname" json:"hostname" validate:"required"`
+
+	// Outputs
+
+	// The Go template of the connection info to return for each instance,
+	// rendered with the instance values. It supports the sprig and helper
+	// functions.
+	ConnectionInfo string `form:"connectionInfo" json:"connectionInfo" validate:"required"`
+
+	// The output format of the connection info, defining how values are
+	// escaped: `text` (as is), `markdown` (as is, with the `mdEscape` function
+	// to escape Markdown special characters) or `html` (escaped according to
+	// the HTML context).
+	ConnectionInfoFormat common.Format `form:"connectionInfoFormat" json:"connectionInfoFormat" validate:"omitempty,oneof=text markdown html" default:"text"`
+
+	// The flags to return for each instance.
+	common.FlagsArgs
+}
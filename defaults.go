@@ -0,0 +1,157 @@
+package recipes
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Defaulter can be implemented by a recipe configuration to set default
+// values that could not be expressed with `default` struct tags (e.g.
+// multi-line templates, or values that depend on other fields).
+// It is called after the struct tags defaults are applied.
+type Defaulter interface {
+	Defaults()
+}
+
+// Defaults sets the `default` struct tags values of all zero-valued fields
+// of the configuration, recursively, then calls its [Defaulter]
+// implementation if any.
+//
+// Tag values are parsed according to the field kind: scalars are parsed
+// as is, slices are comma-separated lists (e.g. `a,b`), and maps are
+// comma-separated k=v pairs (e.g. `cpu=500m,memory=256Mi`).
+func Defaults(conf any) error {
+	if err := setDefaults(reflect.ValueOf(conf)); err != nil {
+		return err
+	}
+
+	if d, ok := conf.(Defaulter); ok {
+		d.Defaults()
+	}
+	return nil
+}
+
+func setDefaults(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return setDefaults(v.Elem())
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			fv := v.Field(i)
+			if def, ok := sf.Tag.Lookup("default"); ok && fv.IsZero() {
+				if err := setDefault(fv, def); err != nil {
+					return errors.Wrapf(err, "invalid default of %s.%s", t.Name(), sf.Name)
+				}
+			}
+			if err := setDefaults(fv); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := setDefaults(v.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		// Map values are not addressable, so work on a copy then put it back
+		iter := v.MapRange()
+		for iter.Next() {
+			val := reflect.New(iter.Value().Type()).Elem()
+			val.Set(iter.Value())
+			if err := setDefaults(val); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), val)
+		}
+	}
+	return nil
+}
+
+func setDefault(v reflect.Value, def string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		ptr := reflect.New(v.Type().Elem())
+		if err := setDefault(ptr.Elem(), def); err != nil {
+			return err
+		}
+		v.Set(ptr)
+
+	case reflect.String:
+		v.SetString(def)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(def, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(def, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(def, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+
+	case reflect.Slice:
+		items := strings.Split(def, ",")
+		sl := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setDefault(sl.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		v.Set(sl)
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return errors.Errorf("unsupported map key kind %s", v.Type().Key().Kind())
+		}
+		mp := reflect.MakeMap(v.Type())
+		for _, pair := range strings.Split(def, ",") {
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return errors.Errorf("invalid k=v pair %q", pair)
+			}
+			ev := reflect.New(v.Type().Elem()).Elem()
+			if err := setDefault(ev, strings.TrimSpace(val)); err != nil {
+				return err
+			}
+			mp.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)).Convert(v.Type().Key()), ev)
+		}
+		v.Set(mp)
+
+	default:
+		return errors.Errorf("unsupported kind %s", v.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package recipes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type defaultsProbe struct {
+	Path   string `form:"path" default:"/healthz"`
+	Period int    `form:"period" default:"10"`
+}
+
+type defaultsContainer struct {
+	Image    string            `form:"image"`
+	Protocol string            `form:"protocol" default:"TCP"`
+	Limits   map[string]string `form:"limits" default:"cpu=500m,memory=256Mi"`
+	Probe    *defaultsProbe    `form:"probe"`
+}
+
+type defaultsConfig struct {
+	Hostname   string                       `form:"hostname"`
+	Replicas   *int                         `form:"replicas" default:"1"`
+	Ratio      float64                      `form:"ratio" default:"0.5"`
+	Enabled    bool                         `form:"enabled" default:"true"`
+	Args       []string                     `form:"args" default:"a, b"`
+	Containers map[string]defaultsContainer `form:"containers"`
+	Sidecars   []defaultsContainer          `form:"sidecars"`
+
+	unexported string `default:"ignored"`
+}
+
+func (conf *defaultsConfig) Defaults() {
+	if conf.Hostname == "" {
+		conf.Hostname = "ctfer.io"
+	}
+}
+
+type invalidDefaultsConfig struct {
+	Port int `form:"port" default:"http"`
+}
+
+func Test_U_Defaults(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Config   *defaultsConfig
+		Expected *defaultsConfig
+	}{
+		"zero": {
+			Config: &defaultsConfig{},
+			Expected: &defaultsConfig{
+				Hostname: "ctfer.io",
+				Replicas: ptr(1),
+				Ratio:    0.5,
+				Enabled:  true,
+				Args:     []string{"a", "b"},
+			},
+		},
+		"set-values-kept": {
+			Config: &defaultsConfig{
+				Hostname: "example.com",
+				Replicas: ptr(0),
+				Ratio:    2,
+				Args:     []string{"c"},
+			},
+			Expected: &defaultsConfig{
+				Hostname: "example.com",
+				Replicas: ptr(0),
+				Ratio:    2,
+				Enabled:  true,
+				Args:     []string{"c"},
+			},
+		},
+		"nested": {
+			Config: &defaultsConfig{
+				Containers: map[string]defaultsContainer{
+					"app": {
+						Image:  "pandatix/license-lvl1:latest",
+						Limits: map[string]string{"cpu": "1"},
+						Probe:  &defaultsProbe{Period: 5},
+					},
+					"db": {
+						Image: "postgres:latest",
+					},
+				},
+				Sidecars: []defaultsContainer{{
+					Image:    "envoyproxy/envoy:latest",
+					Protocol: "UDP",
+				}},
+			},
+			Expected: &defaultsConfig{
+				Hostname: "ctfer.io",
+				Replicas: ptr(1),
+				Ratio:    0.5,
+				Enabled:  true,
+				Args:     []string{"a", "b"},
+				Containers: map[string]defaultsContainer{
+					"app": {
+						Image:    "pandatix/license-lvl1:latest",
+						Protocol: "TCP",
+						Limits:   map[string]string{"cpu": "1"},
+						Probe:    &defaultsProbe{Path: "/healthz", Period: 5},
+					},
+					"db": {
+						Image:    "postgres:latest",
+						Protocol: "TCP",
+						Limits:   map[string]string{"cpu": "500m", "memory": "256Mi"},
+						// Nil pointers to structs are left as is, as there
+						// is nothing to default.
+						Probe: nil,
+					},
+				},
+				Sidecars: []defaultsContainer{{
+					Image:    "envoyproxy/envoy:latest",
+					Protocol: "UDP",
+					Limits:   map[string]string{"cpu": "500m", "memory": "256Mi"},
+				}},
+			},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			err := Defaults(tt.Config)
+			require.NoError(t, err)
+			assert.Equal(t, tt.Expected, tt.Config)
+		})
+	}
+}
+
+func Test_U_DefaultsInvalidTag(t *testing.T) {
+	t.Parallel()
+
+	err := Defaults(&invalidDefaultsConfig{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid default of invalidDefaultsConfig.Port")
+}
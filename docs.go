@@ -0,0 +1,101 @@
+package recipes
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ctfer-io/recipes/internal/gomod"
+)
+
+// Docs are the doc comments of Go types and struct fields, identified by
+// `<package path>.<type>` and `<package path>.<type>.<field>`.
+type Docs map[string]string
+
+// LoadDocs parses the Go sources of the module containing dir to extract
+// the doc comments of types and struct fields.
+// If dir is not part of a Go module (e.g. a recipe binary run out of its
+// sources), no docs are returned.
+func LoadDocs(dir string) (Docs, error) {
+	root, modPath, err := gomod.Find(dir)
+	if err != nil || root == "" {
+		return nil, err
+	}
+
+	docs := Docs{}
+	fset := token.NewFileSet()
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "dist" || d.Name() == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go") {
+			return nil
+		}
+
+		f, err := parser.ParseFile(fset, p, nil, parser.ParseComments)
+		if err != nil {
+			return errors.Wrapf(err, "parsing %s", p)
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		pkgPath := path.Join(modPath, filepath.ToSlash(rel))
+		collectDocs(docs, pkgPath, f)
+		return nil
+	})
+	return docs, err
+}
+
+func collectDocs(docs Docs, pkgPath string, f *ast.File) {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			doc := ts.Doc
+			if doc == nil && len(gd.Specs) == 1 {
+				doc = gd.Doc
+			}
+			key := pkgPath + "." + ts.Name.Name
+			if txt := docText(doc); txt != "" {
+				docs[key] = txt
+			}
+
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			for _, field := range st.Fields.List {
+				txt := docText(field.Doc)
+				if txt == "" {
+					txt = docText(field.Comment)
+				}
+				if txt == "" {
+					continue
+				}
+				for _, name := range field.Names {
+					docs[key+"."+name.Name] = txt
+				}
+			}
+		}
+	}
+}
+
+func docText(cg *ast.CommentGroup) string {
+	return strings.Join(strings.Fields(cg.Text()), " ")
+}
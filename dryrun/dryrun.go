@@ -0,0 +1,271 @@
+// Package dryrun runs recipes offline against Pulumi mocks, such that
+// their registered resources and outputs could be inspected without
+// deploying anything (e.g. asserted in tests).
+package dryrun
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ctfer-io/chall-manager/sdk"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"github.com/ctfer-io/recipes"
+)
+
+const (
+	// Project is the Pulumi project name recipes are run with.
+	Project = "dryrun"
+
+	// Stack is the Pulumi stack name recipes are run with.
+	Stack = "dryrun"
+)
+
+// Resource is a resource registered by a recipe.
+type Resource struct {
+	// Type token of the resource (e.g. `kubernetes:apps/v1:Deployment`).
+	Type string
+
+	// Name of the resource, as given to Pulumi.
+	Name string
+
+	// Inputs of the resource.
+	Inputs resource.PropertyMap
+}
+
+// Get returns the input value at the given path, made of object keys
+// (string) and array indexes (int), or nil if it does not exist.
+// Secret values are returned in clear text (see [Resource.IsSecret]).
+func (r Resource) Get(path ...any) any {
+	v, _, ok := r.lookup(path)
+	if !ok {
+		return nil
+	}
+	return unsecret(v).Mappable()
+}
+
+// IsSecret returns whether the input value at the given path is a secret,
+// or is part of one.
+func (r Resource) IsSecret(path ...any) bool {
+	_, secret, _ := r.lookup(path)
+	return secret
+}
+
+func (r Resource) lookup(path []any) (v resource.PropertyValue, secret bool, ok bool) {
+	v = resource.NewObjectProperty(r.Inputs)
+	for _, p := range path {
+		if v.IsSecret() {
+			secret = true
+			v = v.SecretValue().Element
+		}
+		switch p := p.(type) {
+		case string:
+			if !v.IsObject() {
+				return v, secret, false
+			}
+			v, ok = v.ObjectValue()[resource.PropertyKey(p)]
+			if !ok {
+				return v, secret, false
+			}
+		case int:
+			if !v.IsArray() || p < 0 || p >= len(v.ArrayValue()) {
+				return v, secret, false
+			}
+			v = v.ArrayValue()[p]
+		default:
+			return v, secret, false
+		}
+	}
+	return v, secret || v.IsSecret(), true
+}
+
+// Redacted returns the inputs, where the secret values are replaced by
+// [recipes.Redacted], such that they could be shown.
+func (r Resource) Redacted() map[string]any {
+	return redactSecrets(resource.NewObjectProperty(r.Inputs)).(map[string]any)
+}
+
+func redactSecrets(v resource.PropertyValue) any {
+	switch {
+	case v.IsSecret():
+		return recipes.Redacted
+	case v.IsObject():
+		obj := map[string]any{}
+		for k, e := range v.ObjectValue() {
+			obj[string(k)] = redactSecrets(e)
+		}
+		return obj
+	case v.IsArray():
+		arr := make([]any, len(v.ArrayValue()))
+		for i, e := range v.ArrayValue() {
+			arr[i] = redactSecrets(e)
+		}
+		return arr
+	}
+	return v.Mappable()
+}
+
+// unsecret returns the value with all its secrets in clear text.
+func unsecret(v resource.PropertyValue) resource.PropertyValue {
+	switch {
+	case v.IsSecret():
+		return unsecret(v.SecretValue().Element)
+	case v.IsObject():
+		obj := resource.PropertyMap{}
+		for k, e := range v.ObjectValue() {
+			obj[k] = unsecret(e)
+		}
+		return resource.NewObjectProperty(obj)
+	case v.IsArray():
+		arr := make([]resource.PropertyValue, len(v.ArrayValue()))
+		for i, e := range v.ArrayValue() {
+			arr[i] = unsecret(e)
+		}
+		return resource.NewArrayProperty(arr)
+	}
+	return v
+}
+
+// Result is the outcome of a recipe run.
+type Result struct {
+	// Resources registered by the recipe, in registration order.
+	Resources []Resource
+
+	// ConnectionInfo resolved from the recipe response.
+	ConnectionInfo string
+
+	// ConnectionInfoSecret is whether the response connection info is a
+	// secret output.
+	ConnectionInfoSecret bool
+
+	// Flag resolved from the recipe response.
+	Flag string
+
+	// Flags resolved from the recipe response.
+	Flags []string
+
+	// FlagSecret is whether the response flag is a secret output, i.e.
+	// encrypted in the Pulumi state.
+	FlagSecret bool
+
+	// FlagsSecret is whether the response flags are a secret output.
+	FlagsSecret bool
+}
+
+// Find returns the registered resources of the given type token.
+func (res *Result) Find(typ string) []Resource {
+	out := []Resource{}
+	for _, r := range res.Resources {
+		if r.Type == typ {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Run the recipe factory against Pulumi mocks, with the given identity and
+// additional values, as chall-manager would do for an instance.
+// The additional values go through the same decoding, defaulting and
+// validation as [recipes.Run], and the same middlewares given the options.
+func Run[T any](f recipes.Factory[T], identity string, additionals map[string]string, opts ...recipes.Option[T]) (*Result, error) {
+	mocks := &Mocks{}
+	res := &Result{}
+
+	var resp *sdk.Response
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		req := &sdk.Request{
+			Ctx: ctx,
+			Config: &sdk.Configuration{
+				Identity:   identity,
+				Additional: additionals,
+			},
+		}
+		resp = &sdk.Response{
+			ConnectionInfo: pulumi.String("").ToStringOutput(),
+			Flag:           pulumi.String("").ToStringOutput(),
+			Flags:          pulumi.StringArray{}.ToStringArrayOutput(),
+		}
+
+		if err := recipes.Wrap(f, opts...)(req, resp); err != nil {
+			return err
+		}
+
+		// Export outputs as the SDK does, such that their resolution is
+		// awaited (and errors returned) before the end of the run
+		ctx.Export("connection_info", resp.ConnectionInfo.ApplyT(func(ci string) string {
+			res.ConnectionInfo = ci
+			return ci
+		}))
+		ctx.Export("flag", resp.Flag.ApplyT(func(flag string) string {
+			res.Flag = flag
+			return flag
+		}))
+		ctx.Export("flags", resp.Flags.ApplyT(func(flags []string) []string {
+			res.Flags = flags
+			return flags
+		}))
+		return nil
+	}, pulumi.WithMocks(Project, Stack, mocks))
+	if err != nil {
+		return nil, err
+	}
+
+	res.Resources = mocks.resources
+	res.ConnectionInfoSecret = pulumi.IsSecret(resp.ConnectionInfo)
+	res.FlagSecret = pulumi.IsSecret(resp.Flag)
+	res.FlagsSecret = pulumi.IsSecret(resp.Flags)
+	return res, nil
+}
+
+// Mocks implements [pulumi.MockResourceMonitor] by recording the resources,
+// and emulating the outputs Kubernetes would provide to the recipes.
+// It is safe for concurrent use.
+type Mocks struct {
+	mx        sync.Mutex
+	resources []Resource
+}
+
+var _ pulumi.MockResourceMonitor = (*Mocks)(nil)
+
+func (m *Mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.mx.Lock()
+	m.resources = append(m.resources, Resource{
+		Type:   args.TypeToken,
+		Name:   args.Name,
+		Inputs: args.Inputs,
+	})
+	m.mx.Unlock()
+
+	// Outputs are emulated from the inputs, in clear text
+	outputs := unsecret(resource.NewObjectProperty(args.Inputs)).ObjectValue().Mappable()
+	if args.TypeToken == "kubernetes:core/v1:Service" {
+		spec, _ := outputs["spec"].(map[string]any)
+		switch spec["type"] {
+		case "NodePort":
+			// Give it a node port in the Kubernetes range, derived from the
+			// port such that it is reproducible
+			for _, p := range spec["ports"].([]any) {
+				port := p.(map[string]any)
+				port["nodePort"] = 30000 + int(port["port"].(float64))%2768
+			}
+
+		case "LoadBalancer":
+			// Simulate the external name assigned by the load balancer
+			outputs["status"] = map[string]any{
+				"loadBalancer": map[string]any{
+					"ingress": []any{
+						map[string]any{
+							"hostname": fmt.Sprintf("%s.lb.recipes.test", args.Name),
+						},
+					},
+				},
+			}
+		}
+	}
+	return args.Name + "_id", resource.NewPropertyMapFromMap(outputs), nil
+}
+
+func (m *Mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
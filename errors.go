@@ -0,0 +1,240 @@
+package recipes
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/multierr"
+)
+
+// Fault identifies who is expected to act upon an error.
+type Fault string
+
+const (
+	// FaultAuthor is the fault of the challenge author, who should fix the
+	// additional values (e.g. a missing field, or an invalid template).
+	FaultAuthor Fault = "author"
+
+	// FaultPlatform is the fault of the infrastructure, that the operators
+	// should investigate (e.g. a Kubernetes API error).
+	FaultPlatform Fault = "platform"
+)
+
+// Kind of the errors returned by recipes.
+const (
+	KindDecode       = "decode"
+	KindValidation   = "validation"
+	KindTemplate     = "template"
+	KindProvisioning = "provisioning"
+)
+
+// Error is implemented by all errors returned by recipes, such that they
+// could be routed with [errors.As].
+type Error interface {
+	error
+
+	// Kind of the error, one of KindDecode, KindValidation, KindTemplate or
+	// KindProvisioning.
+	Kind() string
+
+	// Fault returns who is expected to act upon the error.
+	Fault() Fault
+
+	// Paths of the configuration fields at fault, if any, expressed in the
+	// form syntax of additional values.
+	Paths() []string
+
+	// RecipeName returns the name of the recipe the error comes from, if
+	// known.
+	RecipeName() string
+}
+
+// DecodeError is returned when the additional values could not be decoded
+// into the recipe configuration (e.g. an unknown field, or a non-integer
+// port).
+type DecodeError struct {
+	// Recipe name (i.e. Pulumi project name).
+	Recipe string
+
+	// Path of the field that could not be decoded.
+	Path string
+
+	// Err is the underlying error.
+	Err error
+}
+
+var _ Error = (*DecodeError)(nil)
+
+func (err *DecodeError) Error() string {
+	return errorString(err.Recipe, fmt.Sprintf("decoding %s: %s", err.Path, err.Err))
+}
+
+func (err *DecodeError) Unwrap() error      { return err.Err }
+func (err *DecodeError) Kind() string       { return KindDecode }
+func (err *DecodeError) Fault() Fault       { return FaultAuthor }
+func (err *DecodeError) Paths() []string    { return []string{err.Path} }
+func (err *DecodeError) RecipeName() string { return err.Recipe }
+
+// ValidationError is returned when the recipe configuration does not
+// satisfy its constraints.
+type ValidationError struct {
+	// Recipe name (i.e. Pulumi project name).
+	Recipe string
+
+	// Fields that do not satisfy their constraints.
+	Fields []*FieldError
+
+	// Err is the underlying error, aggregating all unsatisfied constraints
+	// (including those that are not related to a specific field).
+	Err error
+}
+
+var _ Error = (*ValidationError)(nil)
+
+func (err *ValidationError) Error() string {
+	return errorString(err.Recipe, fmt.Sprintf("invalid configuration: %s", err.Err))
+}
+
+func (err *ValidationError) Unwrap() error      { return err.Err }
+func (err *ValidationError) Kind() string       { return KindValidation }
+func (err *ValidationError) Fault() Fault       { return FaultAuthor }
+func (err *ValidationError) RecipeName() string { return err.Recipe }
+
+func (err *ValidationError) Paths() []string {
+	paths := make([]string, 0, len(err.Fields))
+	for _, ferr := range err.Fields {
+		paths = append(paths, ferr.Path)
+	}
+	return paths
+}
+
+// TemplateError is returned when a Go template of the configuration could
+// not be parsed or executed (e.g. the connection info).
+type TemplateError struct {
+	// Recipe name (i.e. Pulumi project name).
+	Recipe string
+
+	// Path of the field holding the template.
+	Path string
+
+	// Err is the underlying error.
+	Err error
+}
+
+var _ Error = (*TemplateError)(nil)
+
+func (err *TemplateError) Error() string {
+	return errorString(err.Recipe, fmt.Sprintf("template %s: %s", err.Path, err.Err))
+}
+
+func (err *TemplateError) Unwrap() error      { return err.Err }
+func (err *TemplateError) Kind() string       { return KindTemplate }
+func (err *TemplateError) Fault() Fault       { return FaultAuthor }
+func (err *TemplateError) Paths() []string    { return []string{err.Path} }
+func (err *TemplateError) RecipeName() string { return err.Recipe }
+
+// ProvisioningError is returned when the resources of the recipe could not
+// be created.
+// Any other error returned by a recipe factory is considered as such.
+type ProvisioningError struct {
+	// Recipe name (i.e. Pulumi project name).
+	Recipe string
+
+	// Err is the underlying error.
+	Err error
+}
+
+var _ Error = (*ProvisioningError)(nil)
+
+func (err *ProvisioningError) Error() string {
+	return errorString(err.Recipe, fmt.Sprintf("provisioning: %s", err.Err))
+}
+
+func (err *ProvisioningError) Unwrap() error      { return err.Err }
+func (err *ProvisioningError) Kind() string       { return KindProvisioning }
+func (err *ProvisioningError) Fault() Fault       { return FaultPlatform }
+func (err *ProvisioningError) Paths() []string    { return nil }
+func (err *ProvisioningError) RecipeName() string { return err.Recipe }
+
+func errorString(recipe, msg string) string {
+	if recipe == "" {
+		return msg
+	}
+	return fmt.Sprintf("recipe %s: %s", recipe, msg)
+}
+
+// newValidationError aggregates the unsatisfied constraints, keeping track
+// of those related to a field.
+func newValidationError(err error) *ValidationError {
+	verr := &ValidationError{Err: err}
+	for _, err := range multierr.Errors(err) {
+		var ferr *FieldError
+		if errors.As(err, &ferr) {
+			verr.Fields = append(verr.Fields, ferr)
+		}
+	}
+	return verr
+}
+
+// withRecipe attaches the recipe name to the error, turning it into a
+// [ProvisioningError] if it is not already an [Error].
+// Decode errors are aggregated, so each of them gets it.
+func withRecipe(err error, recipe string) error {
+	if errs := multierr.Errors(err); len(errs) > 1 {
+		var merr error
+		for _, err := range errs {
+			merr = multierr.Append(merr, withRecipe(err, recipe))
+		}
+		return merr
+	}
+
+	var (
+		derr *DecodeError
+		verr *ValidationError
+		terr *TemplateError
+		perr *ProvisioningError
+	)
+	switch {
+	case errors.As(err, &derr):
+		derr.Recipe = recipe
+	case errors.As(err, &verr):
+		verr.Recipe = recipe
+	case errors.As(err, &terr):
+		terr.Recipe = recipe
+	case errors.As(err, &perr):
+		perr.Recipe = recipe
+	default:
+		return &ProvisioningError{Recipe: recipe, Err: err}
+	}
+	return err
+}
+
+// logRecord is the structured form of an [Error], as logged to Pulumi.
+type logRecord struct {
+	Recipe string   `json:"recipe,omitempty"`
+	Kind   string   `json:"kind"`
+	Fault  Fault    `json:"fault"`
+	Paths  []string `json:"paths,omitempty"`
+	Error  string   `json:"error"`
+}
+
+// logError logs the errors as JSON records to Pulumi, such that they could
+// be routed by their kind and fault.
+func logError(ctx *pulumi.Context, err error) {
+	for _, err := range multierr.Errors(err) {
+		var rerr Error
+		if !errors.As(err, &rerr) {
+			continue
+		}
+		b, _ := json.Marshal(logRecord{
+			Recipe: rerr.RecipeName(),
+			Kind:   rerr.Kind(),
+			Fault:  rerr.Fault(),
+			Paths:  rerr.Paths(),
+			Error:  rerr.Error(),
+		})
+		_ = ctx.Log.Error(string(b), nil)
+	}
+}
@@ -0,0 +1,43 @@
+// Package gomod locates the Go module sources are part of.
+package gomod
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Find looks for the go.mod file from dir up to the filesystem root, and
+// returns its directory and module path.
+// If dir is not part of a Go module, root is empty.
+func Find(dir string) (root, modPath string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		f, err := os.Open(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			defer func() {
+				_ = f.Close()
+			}()
+
+			scan := bufio.NewScanner(f)
+			for scan.Scan() {
+				if mp, ok := strings.CutPrefix(strings.TrimSpace(scan.Text()), "module "); ok {
+					return dir, strings.Trim(strings.TrimSpace(mp), `"`), nil
+				}
+			}
+			return "", "", errors.Errorf("no module path in %s", f.Name())
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", nil
+		}
+		dir = parent
+	}
+}
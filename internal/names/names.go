@@ -0,0 +1,21 @@
+// Package names normalises the recipes names as they are published.
+package names
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Normalize a recipe directory name into a Docker-compliant one, as used
+// for its repository and Pulumi project names (e.g. `k8s.E1P` becomes
+// `k8s-e1p`).
+func Normalize(name string) string {
+	return strings.NewReplacer(
+		".", "-",
+	).Replace(strings.ToLower(name))
+}
+
+// Repository returns the Docker Hub repository name of a recipe.
+func Repository(ecosystem, name string) string {
+	return fmt.Sprintf("recipes_%s_%s", ecosystem, Normalize(name))
+}
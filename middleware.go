@@ -0,0 +1,101 @@
+package recipes
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"time"
+
+	"github.com/ctfer-io/chall-manager/sdk"
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Middleware wraps a recipe factory to handle cross-cutting concerns (e.g.
+// logging, tagging resources). It can inspect and modify the request, the
+// response and the resource options before and after calling the next one.
+type Middleware[T any] func(next Factory[T]) Factory[T]
+
+// Option configures [Run] and [Wrap] (e.g. [WithMiddleware]).
+type Option[T any] func(*options[T])
+
+type options[T any] struct {
+	middlewares    []Middleware[T]
+	overridePolicy OverridePolicy
+}
+
+// WithMiddleware adds middlewares around the recipe factory, the first
+// being the outermost.
+// They run after the built-in ones ([Recover], [Timing] and [LogConfig]),
+// once the configuration is decoded, defaulted and validated.
+func WithMiddleware[T any](mws ...Middleware[T]) Option[T] {
+	return func(opts *options[T]) {
+		opts.middlewares = append(opts.middlewares, mws...)
+	}
+}
+
+func newOptions[T any](opts ...Option[T]) *options[T] {
+	options := &options[T]{
+		middlewares: []Middleware[T]{
+			Recover[T],
+			Timing[T],
+			LogConfig[T],
+		},
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// Chain the middlewares around the recipe factory, the first being the
+// outermost.
+func Chain[T any](f Factory[T], mws ...Middleware[T]) Factory[T] {
+	for i := len(mws) - 1; i >= 0; i-- {
+		f = mws[i](f)
+	}
+	return f
+}
+
+// Recover turns a panic of the next factory into a [ProvisioningError],
+// such that it is reported rather than crashing the Pulumi program.
+func Recover[T any](next Factory[T]) Factory[T] {
+	return func(req *Request[T], resp *sdk.Response, opts ...pulumi.ResourceOption) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &ProvisioningError{
+					Err: errors.Errorf("panic: %v\n%s", r, debug.Stack()),
+				}
+			}
+		}()
+		return next(req, resp, opts...)
+	}
+}
+
+// Timing logs how long the next factory took to register the resources.
+func Timing[T any](next Factory[T]) Factory[T] {
+	return func(req *Request[T], resp *sdk.Response, opts ...pulumi.ResourceOption) error {
+		start := time.Now()
+		err := next(req, resp, opts...)
+		_ = req.Ctx.Log.Debug(fmt.Sprintf("recipe factory took %s", time.Since(start)), nil)
+		return err
+	}
+}
+
+// LogConfig logs the decoded configuration as JSON, with its secret fields
+// redacted (see [Redact]).
+// Map configurations (i.e. raw additional values) are not logged, as which
+// of their values are secret is unknown.
+func LogConfig[T any](next Factory[T]) Factory[T] {
+	return func(req *Request[T], resp *sdk.Response, opts ...pulumi.ResourceOption) error {
+		if reflect.TypeFor[T]().Kind() == reflect.Map {
+			return next(req, resp, opts...)
+		}
+		b, err := json.Marshal(Redact(req.Config))
+		if err == nil {
+			_ = req.Ctx.Log.Debug(fmt.Sprintf("recipe configuration: %s", b), nil)
+		}
+		return next(req, resp, opts...)
+	}
+}
@@ -0,0 +1,117 @@
+package recipes
+
+import (
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.uber.org/multierr"
+)
+
+// OverrideTag is the struct tag that marks a configuration field as
+// locked, with `override:"locked"`: when the configuration is given as a
+// [ConfigKey] document, the form keys (e.g. set at the instance level)
+// could not override it nor any of its sub-fields.
+//
+// Chall-manager merges the challenge and instance additional values before
+// they reach the recipe, so the document is considered as the challenge
+// layer, and the other form keys as the overrides. When no document is
+// given, all values are considered as part of the challenge layer.
+const OverrideTag = "override"
+
+// OverridePolicy defines what to do with overrides of locked fields.
+type OverridePolicy int
+
+const (
+	// OverrideReject rejects the configuration with a [ValidationError] on
+	// the overriding keys. This is the default.
+	OverrideReject OverridePolicy = iota
+
+	// OverrideIgnore ignores the overriding keys, and logs them as warnings.
+	OverrideIgnore
+)
+
+// WithOverridePolicy sets what to do with overrides of locked fields (see
+// [OverrideTag]).
+func WithOverridePolicy[T any](policy OverridePolicy) Option[T] {
+	return func(opts *options[T]) {
+		opts.overridePolicy = policy
+	}
+}
+
+// checkOverrides looks for the form keys that target locked fields of the
+// configuration type t, and applies the policy.
+// Ignored keys are removed from vals, and returned sorted.
+func checkOverrides(t reflect.Type, vals url.Values, policy OverridePolicy) ([]string, error) {
+	keys := []string{}
+	for k := range vals {
+		if taggedField(t, k, OverrideTag, "locked") {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	slices.Sort(keys)
+
+	if policy == OverrideReject {
+		verr := &ValidationError{}
+		for _, k := range keys {
+			ferr := &FieldError{Path: k, Rule: "locked"}
+			verr.Fields = append(verr.Fields, ferr)
+			verr.Err = multierr.Append(verr.Err, ferr)
+		}
+		return nil, verr
+	}
+	for _, k := range keys {
+		vals.Del(k)
+	}
+	return keys, nil
+}
+
+// taggedField returns whether the form key targets a field with the struct
+// tag set to value (e.g. a locked field), or one of its sub-fields.
+func taggedField(t reflect.Type, key, tag, value string) bool {
+	for key != "" {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		// Map key or slice index, e.g. `[app]`
+		if strings.HasPrefix(key, "[") {
+			end := strings.Index(key, "]")
+			if end < 0 {
+				return false
+			}
+			switch t.Kind() {
+			case reflect.Map, reflect.Slice, reflect.Array:
+				t = t.Elem()
+			default:
+				return false
+			}
+			key = key[end+1:]
+			continue
+		}
+
+		// Struct field, e.g. `.image`
+		key = strings.TrimPrefix(key, ".")
+		end := strings.IndexAny(key, ".[")
+		if end < 0 {
+			end = len(key)
+		}
+		if t.Kind() != reflect.Struct {
+			return false
+		}
+		sf, ok := formFields(t)[key[:end]]
+		if !ok {
+			return false
+		}
+		if sf.Tag.Get(tag) == value {
+			return true
+		}
+		t = sf.Type
+		key = key[end:]
+	}
+	return false
+}
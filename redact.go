@@ -0,0 +1,200 @@
+package recipes
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces the values of secret fields.
+const Redacted = "[REDACTED]"
+
+// SecretKey is the form key that marks a configuration object as secret
+// in the additional values (e.g. `envs[API_KEY].secret=true`).
+const SecretKey = "secret"
+
+// Secreter is implemented by configuration values that could be marked as
+// secret by the challenge author (e.g. [common.Variable]).
+//
+// [common.Variable]: https://pkg.go.dev/github.com/ctfer-io/recipes/chall-manager/common#Variable
+type Secreter interface {
+	IsSecret() bool
+}
+
+// Redact returns a copy of the configuration suitable for logging, made of
+// maps (keyed by the `form` struct tags), slices and scalars, where the
+// non-empty fields tagged `secret:"true"` and the [Secreter] values that
+// are secret are replaced by [Redacted].
+func Redact(conf any) any {
+	return redact(reflect.ValueOf(conf))
+}
+
+func redact(v reflect.Value) any {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if s, ok := v.Interface().(Secreter); ok && s.IsSecret() && !v.IsZero() {
+		return Redacted
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		out := map[string]any{}
+		redactStruct(out, v)
+		return out
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = redact(iter.Value())
+		}
+		return out
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = redact(v.Index(i))
+		}
+		return out
+
+	case reflect.Invalid:
+		return nil
+	}
+	return v.Interface()
+}
+
+func redactStruct(out map[string]any, v reflect.Value) {
+	for _, f := range FormFields(v.Type()) {
+		fv := v.FieldByIndex(f.Index)
+		if f.Tag.Get("secret") == "true" && !fv.IsZero() {
+			out[f.Name] = Redacted
+			continue
+		}
+		out[f.Name] = redact(fv)
+	}
+}
+
+// RedactAdditionals returns a copy of the raw additional values suitable
+// for logging, where the values of objects marked as secret (i.e. with
+// [SecretKey] set to `true`) are replaced by [Redacted].
+// So are the values of the fields tagged `secret:"true"` in any of the
+// configuration types, as the additional values could target several
+// recipes.
+// This applies to the form keys and the [ConfigKey] document, which is
+// then re-encoded as JSON.
+func RedactAdditionals(additionals map[string]string, types ...reflect.Type) map[string]string {
+	// Look for secret objects prefixes, e.g. `envs[API_KEY].`
+	prefixes := []string{}
+	for k, v := range additionals {
+		if prefix, ok := strings.CutSuffix(k, "."+SecretKey); ok && v == "true" {
+			prefixes = append(prefixes, prefix+".")
+		}
+	}
+
+	out := make(map[string]string, len(additionals))
+	for k, v := range additionals {
+		out[k] = v
+		if k == ConfigKey {
+			out[k] = redactDocument(v, types)
+			continue
+		}
+		if strings.HasSuffix(k, "."+SecretKey) {
+			continue
+		}
+		if v != "" && slices.ContainsFunc(types, func(t reflect.Type) bool {
+			return taggedField(t, k, "secret", "true")
+		}) {
+			out[k] = Redacted
+			continue
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(k, prefix) {
+				out[k] = Redacted
+				break
+			}
+		}
+	}
+	return out
+}
+
+func redactDocument(doc string, types []reflect.Type) string {
+	var raw any
+	if err := yaml.Unmarshal([]byte(doc), &raw); err != nil {
+		// Could not know what is secret, so nothing could be shown
+		return Redacted
+	}
+	b, err := json.Marshal(redactRaw(raw, types))
+	if err != nil {
+		return Redacted
+	}
+	return string(b)
+}
+
+// redactRaw redacts the decoded document, walked along with the
+// configuration types it could be decoded into.
+func redactRaw(raw any, types []reflect.Type) any {
+	switch raw := raw.(type) {
+	case map[string]any:
+		secret := raw[SecretKey] == true
+		out := make(map[string]any, len(raw))
+		for k, v := range raw {
+			if secret && k != SecretKey {
+				out[k] = Redacted
+				continue
+			}
+			sub, tagged := fieldTypes(types, k)
+			if tagged && v != nil && v != "" {
+				out[k] = Redacted
+				continue
+			}
+			out[k] = redactRaw(v, sub)
+		}
+		return out
+
+	case []any:
+		sub, _ := fieldTypes(types, "")
+		out := make([]any, len(raw))
+		for i, v := range raw {
+			out[i] = redactRaw(v, sub)
+		}
+		return out
+	}
+	return raw
+}
+
+// fieldTypes returns the types of the document key in the configuration
+// types (i.e. of a struct field, or of map values and slice elements), and
+// whether any is a field tagged `secret:"true"`.
+func fieldTypes(types []reflect.Type, key string) ([]reflect.Type, bool) {
+	sub := []reflect.Type{}
+	tagged := false
+	for _, t := range types {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			if sf, ok := formFields(t)[key]; ok {
+				tagged = tagged || sf.Tag.Get("secret") == "true"
+				sub = append(sub, sf.Type)
+			}
+		case reflect.Map, reflect.Slice, reflect.Array:
+			sub = append(sub, t.Elem())
+		}
+	}
+	return sub, tagged
+}
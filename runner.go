@@ -1,36 +1,377 @@
 package recipes
 
 import (
+	"fmt"
 	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/ctfer-io/chall-manager/sdk"
 	"github.com/go-playground/form/v4"
+	"github.com/pkg/errors"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/multierr"
+	"gopkg.in/yaml.v3"
 )
 
+// ConfigKey is the reserved additional value key that may contain a JSON
+// or YAML document of the whole configuration.
+// When set, this document is decoded first, then all other additional
+// values (form keys) are decoded over it such that they act as overrides.
+const ConfigKey = "config"
+
 type Request[T any] struct {
 	Ctx      *pulumi.Context
 	Identity string
 	Config   *T
+
+	// Additional values, raw as chall-manager provided them (i.e. the
+	// challenge ones overridden by the instance ones).
+	Additional map[string]string
+
+	// Metadata of the request.
+	Metadata Metadata
+
+	// Flags produced for this instance, if the configuration is a
+	// [Flagger]. They are already set in the response.
+	Flags []string
+
+	// SecretFlags is whether the flags are secret (see [Flagger]), such
+	// that the values rendered out of them should be too.
+	SecretFlags bool
+}
+
+// Metadata of a request, as provided by chall-manager and Pulumi.
+// Chall-manager does not tell apart the challenge and instance additional
+// values, nor the instance lifetime, so neither are available.
+type Metadata struct {
+	// Project is the Pulumi project name.
+	Project string `json:"project"`
+
+	// Stack is the Pulumi stack name.
+	Stack string `json:"stack"`
+
+	// Organization is the Pulumi organization name.
+	Organization string `json:"organization"`
+
+	// DryRun is true when the resources are previewed rather than deployed.
+	DryRun bool `json:"dryRun"`
+
+	// Namespace is the Kubernetes namespace the resources are deployed in,
+	// if chall-manager targets one (i.e. through the
+	// `KUBERNETES_TARGET_NAMESPACE` environment variable).
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// NamespaceEnv is the environment variable chall-manager sets to the
+// Kubernetes namespace to deploy the resources in.
+const NamespaceEnv = "KUBERNETES_TARGET_NAMESPACE"
+
+// Flagger is implemented by configurations that define flags, e.g. by
+// embedding [common.FlagsArgs].
+//
+// [common.FlagsArgs]: https://pkg.go.dev/github.com/ctfer-io/recipes/chall-manager/common#FlagsArgs
+type Flagger interface {
+	ProduceFlags(seed string) []string
+
+	// SecretFlags returns whether the flags are secret, such that they are
+	// encrypted in the Pulumi state.
+	SecretFlags() bool
 }
 
 type Factory[T any] func(req *Request[T], resp *sdk.Response, opts ...pulumi.ResourceOption) error
 
-func Run[T any](f Factory[T]) {
-	sdk.Run(func(req *sdk.Request, resp *sdk.Response, opts ...pulumi.ResourceOption) error {
-		conf := new(T)
+func Run[T any](f Factory[T], opts ...Option[T]) {
+	if path, ok := os.LookupEnv(SchemaEnv); ok {
+		if err := writeSchema[T](path); err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] writing schema: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	sdk.Run(Wrap(f, opts...))
+}
+
+// Wrap turns a recipe factory into a chall-manager SDK one.
+// The additional values are decoded into the configuration, defaulted and
+// validated before the recipe factory is called.
+// The recipe factory is wrapped by the middlewares (see [WithMiddleware]).
+// Returned errors are [Error] (any error of the factory that is not already
+// one is a [ProvisioningError]) and are logged to Pulumi as JSON records.
+func Wrap[T any](f Factory[T], opts ...Option[T]) sdk.Factory {
+	options := newOptions(opts...)
+	f = Chain(f, options.middlewares...)
+
+	return func(req *sdk.Request, resp *sdk.Response, opts ...pulumi.ResourceOption) error {
+		err := wrap(f, options, req, resp, opts...)
+		if err != nil {
+			err = withRecipe(err, req.Ctx.Project())
+			logError(req.Ctx, err)
+		}
+		return err
+	}
+}
+
+func wrap[T any](f Factory[T], options *options[T], req *sdk.Request, resp *sdk.Response, opts ...pulumi.ResourceOption) error {
+	conf, ignored, err := decodeConfig(req.Config.Additional, options)
+	if err != nil {
+		return err
+	}
+	for _, key := range ignored {
+		_ = req.Ctx.Log.Warn(fmt.Sprintf("ignoring override of locked field %s", key), nil)
+	}
+
+	// Produce flags such that the factory could use them, and return them
+	// to chall-manager (unless the factory overwrites them)
+	var flags []string
+	secret := false
+	if fl, ok := any(conf).(Flagger); ok {
+		flags = fl.ProduceFlags(req.Config.Identity)
+		secret = fl.SecretFlags()
+	}
+	if len(flags) != 0 {
+		resp.Flag = pulumi.String(flags[0]).ToStringOutput()
+		resp.Flags = pulumi.ToStringArray(flags).ToStringArrayOutput()
+		if secret {
+			// As the flags are returned together, one secret makes them all
+			resp.Flag = pulumi.ToSecret(resp.Flag).(pulumi.StringOutput)
+			resp.Flags = pulumi.ToSecret(resp.Flags).(pulumi.StringArrayOutput)
+		}
+	}
+
+	return f(&Request[T]{
+		Ctx:        req.Ctx,
+		Identity:   req.Config.Identity,
+		Config:     conf,
+		Additional: req.Config.Additional,
+		Metadata: Metadata{
+			Project:      req.Ctx.Project(),
+			Stack:        req.Ctx.Stack(),
+			Organization: req.Ctx.Organization(),
+			DryRun:       req.Ctx.DryRun(),
+			Namespace:    os.Getenv(NamespaceEnv),
+		},
+		Flags:       flags,
+		SecretFlags: secret,
+	}, resp, opts...)
+}
+
+// Decode the additional values into the recipe configuration, then apply
+// its defaults and validate it, as [Run] does before calling the recipe
+// factory.
+func Decode[T any](additionals map[string]string, opts ...Option[T]) (*T, error) {
+	conf, _, err := decodeConfig(additionals, newOptions(opts...))
+	return conf, err
+}
+
+func decodeConfig[T any](additionals map[string]string, options *options[T]) (*T, []string, error) {
+	conf := new(T)
+	ignored, err := decode(conf, additionals, options.overridePolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := Defaults(conf); err != nil {
+		// Struct tags are not the challenge author fault
+		return nil, nil, errors.Wrap(err, "applying defaults")
+	}
 
-		dec := form.NewDecoder()
-		if err := dec.Decode(conf, toValues(req.Config.Additional)); err != nil {
-			return err
+	// Validate ASAP -> fail fast
+	if err := Validate(conf); err != nil {
+		return nil, nil, newValidationError(err)
+	}
+	return conf, ignored, nil
+}
+
+// decode the additional values into the configuration: first the
+// structured document under [ConfigKey] (if any) as the base, then the
+// form keys as overrides, unless they target locked fields (see
+// [OverridePolicy]), in which case the ignored keys are returned.
+// Configurations that are not structs (e.g. raw maps) do not support the
+// structured document, so receive it as any other key.
+func decode(conf any, additionals map[string]string, policy OverridePolicy) (ignored []string, err error) {
+	vals := toValues(additionals)
+	switch t := reflect.TypeOf(conf).Elem(); t.Kind() {
+	case reflect.Map:
+		// Raw maps are set as is, as the form decoder would parse their
+		// keys (e.g. `envs[FLAG].content`) as paths
+		if t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.String {
+			mp := reflect.MakeMapWithSize(t, len(additionals))
+			for k, v := range additionals {
+				mp.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), reflect.ValueOf(v).Convert(t.Elem()))
+			}
+			reflect.ValueOf(conf).Elem().Set(mp)
+			return nil, nil
 		}
 
-		return f(&Request[T]{
-			Ctx:      req.Ctx,
-			Identity: req.Config.Identity,
-			Config:   conf,
-		}, resp, opts...)
-	})
+	case reflect.Struct:
+		if doc, ok := additionals[ConfigKey]; ok {
+			base, err := documentValues(t, doc)
+			if err != nil {
+				return nil, err
+			}
+			vals.Del(ConfigKey)
+			ignored, err = checkOverrides(t, vals, policy)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range vals {
+				base[k] = v
+			}
+			vals = base
+		}
+	}
+
+	dec := form.NewDecoder()
+	err = dec.Decode(conf, vals)
+
+	// Report each field that could not be decoded, in a stable order
+	var derrs form.DecodeErrors
+	if !errors.As(err, &derrs) {
+		return ignored, err
+	}
+	paths := make([]string, 0, len(derrs))
+	for path := range derrs {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	var merr error
+	for _, path := range paths {
+		merr = multierr.Append(merr, &DecodeError{Path: path, Err: derrs[path]})
+	}
+	return nil, merr
+}
+
+// documentValues parses a JSON or YAML document (YAML being a superset of
+// JSON) and flattens it into form values, guided by the configuration type
+// such that it shares the same keys as the additional values.
+func documentValues(t reflect.Type, doc string) (url.Values, error) {
+	var raw any
+	if err := yaml.Unmarshal([]byte(doc), &raw); err != nil {
+		return nil, &DecodeError{Path: ConfigKey, Err: err}
+	}
+
+	vals := url.Values{}
+	if err := flatten(vals, t, "", raw); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+func flatten(vals url.Values, t reflect.Type, path string, raw any) error {
+	if raw == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		mp, ok := raw.(map[string]any)
+		if !ok {
+			return &DecodeError{Path: pathOrRoot(path), Err: errors.New("expected an object")}
+		}
+		fields := formFields(t)
+		for k, v := range mp {
+			sf, ok := fields[k]
+			if !ok {
+				return &DecodeError{Path: subpath(path, k), Err: errors.New("unknown field")}
+			}
+			if err := flatten(vals, sf.Type, subpath(path, k), v); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		mp, ok := raw.(map[string]any)
+		if !ok {
+			return &DecodeError{Path: pathOrRoot(path), Err: errors.New("expected an object")}
+		}
+		for k, v := range mp {
+			if err := flatten(vals, t.Elem(), fmt.Sprintf("%s[%s]", path, k), v); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		arr, ok := raw.([]any)
+		if !ok {
+			return &DecodeError{Path: pathOrRoot(path), Err: errors.New("expected an array")}
+		}
+		for i, v := range arr {
+			if err := flatten(vals, t.Elem(), fmt.Sprintf("%s[%d]", path, i), v); err != nil {
+				return err
+			}
+		}
+
+	default:
+		vals.Set(path, fmt.Sprint(raw))
+	}
+	return nil
+}
+
+// FormField is a struct field of a configuration, identified by its form
+// name (i.e. the key in the additional values).
+type FormField struct {
+	// Name is the form name of the field.
+	Name string
+
+	// StructField is the field, whose Index is relative to the struct
+	// type it was looked up in (see [reflect.Value.FieldByIndex]).
+	reflect.StructField
+}
+
+// FormFields returns the struct fields of t, in declaration order.
+// Embedded structs fields are promoted, as the form decoder does.
+func FormFields(t reflect.Type) []FormField {
+	fields := []FormField{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("form"), ",")
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			for _, ef := range FormFields(sf.Type) {
+				ef.Index = append([]int{i}, ef.Index...)
+				fields = append(fields, ef)
+			}
+			continue
+		}
+		if !sf.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, FormField{Name: name, StructField: sf})
+	}
+	return fields
+}
+
+// formFields returns the struct fields, identified by their form name
+// (see [FormFields]).
+func formFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for _, f := range FormFields(t) {
+		fields[f.Name] = f.StructField
+	}
+	return fields
+}
+
+func subpath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return ConfigKey
+	}
+	return path
 }
 
 func toValues(additionals map[string]string) url.Values {
@@ -0,0 +1,302 @@
+package recipes
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type documentPort struct {
+	Port     int    `form:"port"`
+	Protocol string `form:"protocol"`
+}
+
+type documentBase struct {
+	RunAsUser int `form:"runAsUser"`
+}
+
+type documentContainer struct {
+	documentBase
+
+	Image string            `form:"image"`
+	Ports []documentPort    `form:"ports"`
+	Envs  map[string]string `form:"envs"`
+}
+
+type documentConfig struct {
+	Hostname   string                        `form:"hostname"`
+	Replicas   *int                          `form:"replicas"`
+	Containers map[string]*documentContainer `form:"containers"`
+	Image      string                        `form:"image" override:"locked"`
+	Skipped    string                        `form:"-"`
+}
+
+func Test_U_DocumentValues(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Document         string
+		ExpectedValues   url.Values
+		ExpectedErrPath  string
+		ExpectedErrCause string
+	}{
+		"yaml": {
+			Document: `
+hostname: ctfer.io
+replicas: 2
+containers:
+  app:
+    image: pandatix/license-lvl1:latest
+    runAsUser: 1000
+    ports:
+      - port: 8080
+        protocol: TCP
+      - port: 8081
+    envs:
+      LEVEL: "1"
+`,
+			ExpectedValues: url.Values{
+				"hostname":                          {"ctfer.io"},
+				"replicas":                          {"2"},
+				"containers[app].image":             {"pandatix/license-lvl1:latest"},
+				"containers[app].runAsUser":         {"1000"},
+				"containers[app].ports[0].port":     {"8080"},
+				"containers[app].ports[0].protocol": {"TCP"},
+				"containers[app].ports[1].port":     {"8081"},
+				"containers[app].envs[LEVEL]":       {"1"},
+			},
+		},
+		"json": {
+			Document: `{"hostname": "ctfer.io", "containers": {"app": {"ports": [{"port": 8080}]}}}`,
+			ExpectedValues: url.Values{
+				"hostname":                      {"ctfer.io"},
+				"containers[app].ports[0].port": {"8080"},
+			},
+		},
+		"null-values": {
+			Document: `
+hostname: ctfer.io
+replicas: null
+`,
+			ExpectedValues: url.Values{
+				"hostname": {"ctfer.io"},
+			},
+		},
+		"empty": {
+			Document:       ``,
+			ExpectedValues: url.Values{},
+		},
+		"invalid-syntax": {
+			Document:        `hostname: [ctfer.io`,
+			ExpectedErrPath: ConfigKey,
+		},
+		"not-an-object": {
+			Document:         `- ctfer.io`,
+			ExpectedErrPath:  ConfigKey,
+			ExpectedErrCause: "expected an object",
+		},
+		"unknown-field": {
+			Document: `
+containers:
+  app:
+    imag: pandatix/license-lvl1:latest
+`,
+			ExpectedErrPath:  "containers[app].imag",
+			ExpectedErrCause: "unknown field",
+		},
+		"ignored-field": {
+			Document:         `Skipped: value`,
+			ExpectedErrPath:  "Skipped",
+			ExpectedErrCause: "unknown field",
+		},
+		"map-type-mismatch": {
+			Document:         `containers: [app]`,
+			ExpectedErrPath:  "containers",
+			ExpectedErrCause: "expected an object",
+		},
+		"slice-type-mismatch": {
+			Document: `
+containers:
+  app:
+    ports:
+      port: 8080
+`,
+			ExpectedErrPath:  "containers[app].ports",
+			ExpectedErrCause: "expected an array",
+		},
+		"nested-type-mismatch": {
+			Document: `
+containers:
+  app:
+    ports:
+      - 8080
+`,
+			ExpectedErrPath:  "containers[app].ports[0]",
+			ExpectedErrCause: "expected an object",
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			vals, err := documentValues(reflect.TypeFor[documentConfig](), tt.Document)
+			if tt.ExpectedErrPath != "" {
+				var derr *DecodeError
+				require.ErrorAs(t, err, &derr)
+				assert.Equal(t, tt.ExpectedErrPath, derr.Path)
+				if tt.ExpectedErrCause != "" {
+					assert.EqualError(t, derr.Err, tt.ExpectedErrCause)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.ExpectedValues, vals)
+		})
+	}
+}
+
+func Test_U_Decode(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Additionals     map[string]string
+		Policy          OverridePolicy
+		Expected        *documentConfig
+		ExpectedIgnored []string
+		ExpectErr       bool
+	}{
+		"form-only": {
+			Additionals: map[string]string{
+				"hostname":              "ctfer.io",
+				"image":                 "pandatix/license-lvl1:latest",
+				"containers[app].image": "pandatix/license-lvl2:latest",
+			},
+			Expected: &documentConfig{
+				Hostname: "ctfer.io",
+				Image:    "pandatix/license-lvl1:latest",
+				Containers: map[string]*documentContainer{
+					"app": {Image: "pandatix/license-lvl2:latest"},
+				},
+			},
+		},
+		"document-only": {
+			Additionals: map[string]string{
+				ConfigKey: `
+hostname: ctfer.io
+image: pandatix/license-lvl1:latest
+`,
+			},
+			Expected: &documentConfig{
+				Hostname: "ctfer.io",
+				Image:    "pandatix/license-lvl1:latest",
+			},
+		},
+		"form-over-document": {
+			Additionals: map[string]string{
+				ConfigKey: `
+hostname: ctfer.io
+replicas: 1
+containers:
+  app:
+    image: pandatix/license-lvl1:latest
+    ports:
+      - port: 8080
+`,
+				"replicas":                      "3",
+				"containers[app].ports[0].port": "8081",
+				"containers[app].runAsUser":     "1000",
+			},
+			Expected: &documentConfig{
+				Hostname: "ctfer.io",
+				Replicas: ptr(3),
+				Containers: map[string]*documentContainer{
+					"app": {
+						documentBase: documentBase{RunAsUser: 1000},
+						Image:        "pandatix/license-lvl1:latest",
+						Ports:        []documentPort{{Port: 8081}},
+					},
+				},
+			},
+		},
+		"locked-rejected": {
+			Additionals: map[string]string{
+				ConfigKey: `image: pandatix/license-lvl1:latest`,
+				"image":   "attacker/miner:latest",
+			},
+			ExpectErr: true,
+		},
+		"locked-ignored": {
+			Additionals: map[string]string{
+				ConfigKey:  `image: pandatix/license-lvl1:latest`,
+				"image":    "attacker/miner:latest",
+				"hostname": "ctfer.io",
+			},
+			Policy: OverrideIgnore,
+			Expected: &documentConfig{
+				Hostname: "ctfer.io",
+				Image:    "pandatix/license-lvl1:latest",
+			},
+			ExpectedIgnored: []string{"image"},
+		},
+		"type-mismatch": {
+			Additionals: map[string]string{
+				"replicas": "many",
+			},
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			conf := &documentConfig{}
+			ignored, err := decode(conf, tt.Additionals, tt.Policy)
+			if tt.ExpectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.Expected, conf)
+			assert.Equal(t, tt.ExpectedIgnored, ignored)
+		})
+	}
+}
+
+func Test_U_DecodeMap(t *testing.T) {
+	t.Parallel()
+
+	// Raw maps receive the document as any other key
+	additionals := map[string]string{
+		ConfigKey:                  `hostname: ctfer.io`,
+		"hostname":                 "example.com",
+		"envs[FLAG].content":       "{{ .Flag }}",
+		"containers[app].ports[0]": "8080",
+	}
+	conf := map[string]string{}
+	ignored, err := decode(&conf, additionals, OverrideReject)
+	require.NoError(t, err)
+	assert.Empty(t, ignored)
+	assert.Equal(t, additionals, conf)
+}
+
+func Test_U_FormFields(t *testing.T) {
+	t.Parallel()
+
+	fields := FormFields(reflect.TypeFor[documentContainer]())
+
+	names := []string{}
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"runAsUser", "image", "ports", "envs"}, names)
+
+	// Promoted fields are reachable from the outer struct
+	v := reflect.ValueOf(documentContainer{documentBase: documentBase{RunAsUser: 1000}})
+	assert.Equal(t, []int{0, 0}, fields[0].Index)
+	assert.Equal(t, 1000, v.FieldByIndex(fields[0].Index).Interface())
+}
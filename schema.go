@@ -0,0 +1,238 @@
+package recipes
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SchemaEnv is the environment variable that, when set to a file path,
+// makes [Run] write the JSON Schema of the recipe configuration into it
+// rather than running the scenario.
+// This is used by the generator to ship the schema along the recipe.
+const SchemaEnv = "RECIPES_SCHEMA"
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+const cidrPattern = `^[0-9a-fA-F:.]+/[0-9]{1,3}$`
+
+// Schema is a subset of JSON Schema (draft 2020-12), sufficient to describe
+// recipes configurations.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+}
+
+// SchemaOf generates the JSON Schema of a recipe configuration.
+// Properties are named after the `form` struct tags, constraints are
+// extracted from the `validate` ones, and defaults from both the `default`
+// ones and the [Defaulter] implementation (top-level fields only).
+// Descriptions are looked up in docs (see [LoadDocs]), if any.
+func SchemaOf[T any](docs Docs) *Schema {
+	def := new(T)
+	_ = Defaults(def)
+
+	s := schemaOf(reflect.TypeOf(def).Elem(), reflect.ValueOf(def).Elem(), docs)
+	s.Schema = schemaDraft
+	return s
+}
+
+func writeSchema[T any](path string) error {
+	// Docs are only available when run from within the sources
+	docs, err := LoadDocs(".")
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(SchemaOf[T](docs), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+func schemaOf(t reflect.Type, def reflect.Value, docs Docs) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		s := &Schema{
+			Type:        "object",
+			Description: docs[t.PkgPath()+"."+t.Name()],
+			Properties:  map[string]*Schema{},
+		}
+		for _, f := range FormFields(t) {
+			sf, name := f.StructField, f.Name
+
+			var fdef reflect.Value
+			if def.IsValid() {
+				fdef = def.FieldByIndex(sf.Index)
+			}
+			// Promoted fields are documented in their own struct
+			owner := t
+			if len(sf.Index) > 1 {
+				owner = t.FieldByIndex(sf.Index[:len(sf.Index)-1]).Type
+			}
+			fs := schemaOf(sf.Type, reflect.Value{}, docs)
+			fs.Description = docs[owner.PkgPath()+"."+owner.Name()+"."+sf.Name]
+			if tag, ok := sf.Tag.Lookup("default"); ok {
+				v := reflect.New(sf.Type).Elem()
+				if err := setDefault(v, tag); err == nil {
+					fs.Default = v.Interface()
+				}
+			} else if fdef.IsValid() && !fdef.IsZero() {
+				fs.Default = fdef.Interface()
+			}
+			if applyRules(fs, sf.Type, sf.Tag.Get("validate")) {
+				s.Required = append(s.Required, name)
+			}
+			s.Properties[name] = fs
+		}
+		return s
+
+	case reflect.Map:
+		return &Schema{
+			Type:                 "object",
+			AdditionalProperties: schemaOf(t.Elem(), reflect.Value{}, docs),
+		}
+
+	case reflect.Slice, reflect.Array:
+		return &Schema{
+			Type:  "array",
+			Items: schemaOf(t.Elem(), reflect.Value{}, docs),
+		}
+
+	case reflect.String:
+		return &Schema{Type: "string"}
+
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	}
+	return &Schema{}
+}
+
+// applyRules translates the `validate` rules into JSON Schema constraints,
+// and returns whether the field is required.
+// Rules after `dive` apply to the elements (and keys between `keys` and
+// `endkeys`).
+func applyRules(s *Schema, t reflect.Type, rules string) (required bool) {
+	if rules == "" {
+		return false
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	own, elem, dive := strings.Cut(rules, ",dive")
+	if strings.HasPrefix(rules, "dive") {
+		own, elem, dive = "", strings.TrimPrefix(rules, "dive"), true
+	}
+	for _, rule := range strings.Split(own, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+
+		case "min", "max":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array:
+				if name == "min" {
+					s.MinItems = ptr(int(n))
+				} else {
+					s.MaxItems = ptr(int(n))
+				}
+			case reflect.Map:
+				if name == "min" {
+					s.MinProperties = ptr(int(n))
+				} else {
+					s.MaxProperties = ptr(int(n))
+				}
+			case reflect.String:
+				if name == "min" {
+					s.MinLength = ptr(int(n))
+				} else {
+					s.MaxLength = ptr(int(n))
+				}
+			default:
+				if name == "min" {
+					s.Minimum = ptr(n)
+				} else {
+					s.Maximum = ptr(n)
+				}
+			}
+
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				if t.Kind() == reflect.String {
+					s.Enum = append(s.Enum, v)
+				} else if n, err := strconv.ParseFloat(v, 64); err == nil {
+					s.Enum = append(s.Enum, n)
+				}
+			}
+
+		case "cidr":
+			// Not a JSON Schema format, so only check the shape of IPv4 and
+			// IPv6 CIDR notations
+			s.Pattern = cidrPattern
+
+		case "startswith":
+			s.Pattern = "^" + regexp.QuoteMeta(param)
+		}
+	}
+	if !dive {
+		return
+	}
+
+	// Element rules, possibly prefixed by keys rules
+	elem = strings.TrimPrefix(elem, ",")
+	if keys, rest, ok := strings.Cut(elem, ",endkeys"); ok && strings.HasPrefix(keys, "keys") {
+		s.PropertyNames = &Schema{Type: "string"}
+		applyRules(s.PropertyNames, t.Key(), strings.TrimPrefix(strings.TrimPrefix(keys, "keys"), ","))
+		elem = strings.TrimPrefix(rest, ",")
+	}
+	switch {
+	case s.Items != nil:
+		applyRules(s.Items, t.Elem(), elem)
+	case s.AdditionalProperties != nil:
+		applyRules(s.AdditionalProperties, t.Elem(), elem)
+	}
+	return
+}
+
+func ptr[T any](t T) *T {
+	return &t
+}
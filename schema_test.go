@@ -0,0 +1,122 @@
+package recipes
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type schemaSecurity struct {
+	RunAsUser *int `form:"runAsUser" validate:"omitempty,min=0"`
+}
+
+type schemaPort struct {
+	Port     int    `form:"port" validate:"required,min=1,max=65535"`
+	Protocol string `form:"protocol" validate:"omitempty,oneof=TCP UDP" default:"TCP"`
+}
+
+type schemaConfig struct {
+	schemaSecurity
+
+	Hostname       string            `form:"hostname" validate:"required,min=1,max=253"`
+	ConnectionInfo string            `form:"connectionInfo"`
+	Ports          []schemaPort      `form:"ports" validate:"required,min=1,max=8,dive"`
+	Files          map[string]string `form:"files" validate:"omitempty,max=4,dive,keys,startswith=/,endkeys"`
+	Labels         map[string]string `form:"labels" validate:"omitempty,min=1"`
+	AllowedCIDR    string            `form:"allowedCidr" validate:"omitempty,cidr"`
+	Internal       string            `form:"-"`
+}
+
+func (conf *schemaConfig) Defaults() {
+	if conf.ConnectionInfo == "" {
+		conf.ConnectionInfo = "{{ .Hostname }}"
+	}
+}
+
+func Test_U_SchemaOf(t *testing.T) {
+	t.Parallel()
+
+	docs := Docs{
+		"github.com/ctfer-io/recipes.schemaConfig":          "The fixture configuration.",
+		"github.com/ctfer-io/recipes.schemaConfig.Hostname": "The hostname.",
+		"github.com/ctfer-io/recipes.schemaPort.Port":       "The port to expose.",
+	}
+	s := SchemaOf[schemaConfig](docs)
+
+	expected := &Schema{
+		Schema:      schemaDraft,
+		Type:        "object",
+		Description: "The fixture configuration.",
+		Required:    []string{"hostname", "ports"},
+		Properties: map[string]*Schema{
+			"runAsUser": {
+				Type:    "integer",
+				Minimum: ptr(0.),
+			},
+			"hostname": {
+				Type:        "string",
+				Description: "The hostname.",
+				MinLength:   ptr(1),
+				MaxLength:   ptr(253),
+			},
+			"connectionInfo": {
+				Type:    "string",
+				Default: "{{ .Hostname }}",
+			},
+			"ports": {
+				Type:     "array",
+				MinItems: ptr(1),
+				MaxItems: ptr(8),
+				Items: &Schema{
+					Type:     "object",
+					Required: []string{"port"},
+					Properties: map[string]*Schema{
+						"port": {
+							Type:        "integer",
+							Description: "The port to expose.",
+							Minimum:     ptr(1.),
+							Maximum:     ptr(65535.),
+						},
+						"protocol": {
+							Type:    "string",
+							Enum:    []any{"TCP", "UDP"},
+							Default: "TCP",
+						},
+					},
+				},
+			},
+			"files": {
+				Type:          "object",
+				MaxProperties: ptr(4),
+				PropertyNames: &Schema{
+					Type:    "string",
+					Pattern: "^/",
+				},
+				AdditionalProperties: &Schema{Type: "string"},
+			},
+			"labels": {
+				Type:                 "object",
+				MinProperties:        ptr(1),
+				AdditionalProperties: &Schema{Type: "string"},
+			},
+			"allowedCidr": {
+				Type:    "string",
+				Pattern: cidrPattern,
+			},
+		},
+	}
+	assert.Equal(t, expected, s)
+
+	// The schema is valid JSON, without any non-standard format
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), `"format"`)
+
+	cidr := regexp.MustCompile(cidrPattern)
+	assert.True(t, cidr.MatchString("10.0.0.0/8"))
+	assert.True(t, cidr.MatchString("fd00::/64"))
+	assert.False(t, cidr.MatchString("10.0.0.0"))
+}
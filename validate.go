@@ -0,0 +1,99 @@
+package recipes
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// Validator can be implemented by a recipe configuration to run checks
+// that could not be expressed with `validate` struct tags (e.g. cross-field
+// constraints).
+// It is called after the struct tags constraints are checked.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError is a constraint that a configuration field does not satisfy.
+type FieldError struct {
+	// Path of the field, expressed in the form syntax of additional values
+	// (e.g. `containers[app].ports[0].port`).
+	Path string
+
+	// Rule that is not satisfied (e.g. `required`, `oneof=TCP UDP`).
+	Rule string
+}
+
+func (err *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Path, err.Rule)
+}
+
+var validate = func() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Use the form tags to report field paths the same way challenge
+	// authors wrote them in the additional values.
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name, _, _ := strings.Cut(fld.Tag.Get("form"), ",")
+		if name == "-" {
+			return ""
+		}
+		if fld.Anonymous && name == "" {
+			return embedded
+		}
+		return name
+	})
+	return v
+}()
+
+// Validate checks the configuration against its `validate` struct tags,
+// then calls its [Validator] implementation if any.
+// All unsatisfied constraints are aggregated in the returned error.
+func Validate(conf any) (merr error) {
+	if reflect.Indirect(reflect.ValueOf(conf)).Kind() == reflect.Struct {
+		if err := validate.Struct(conf); err != nil {
+			var verrs validator.ValidationErrors
+			if !errors.As(err, &verrs) {
+				return err
+			}
+			for _, ferr := range verrs {
+				merr = multierr.Append(merr, &FieldError{
+					Path: fieldPath(ferr.Namespace()),
+					Rule: fieldRule(ferr),
+				})
+			}
+		}
+	}
+
+	if v, ok := conf.(Validator); ok {
+		merr = multierr.Append(merr, v.Validate())
+	}
+	return
+}
+
+// embedded names the embedded structs in validator namespaces, as their
+// fields are promoted in the form syntax.
+const embedded = "<embedded>"
+
+// fieldPath drops the root struct name and the embedded structs of a
+// validator namespace, so `Config.containers[app].<embedded>.runAsUser`
+// becomes `containers[app].runAsUser`.
+func fieldPath(ns string) string {
+	ns = strings.ReplaceAll(ns, embedded+".", "")
+	_, path, ok := strings.Cut(ns, ".")
+	if !ok {
+		return ns
+	}
+	return path
+}
+
+func fieldRule(ferr validator.FieldError) string {
+	if ferr.Param() == "" {
+		return ferr.Tag()
+	}
+	return fmt.Sprintf("%s=%s", ferr.Tag(), ferr.Param())
+}
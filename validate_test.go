@@ -0,0 +1,139 @@
+package recipes
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+)
+
+type validateBase struct {
+	RunAsUser int `form:"runAsUser" validate:"gte=0"`
+}
+
+type validatePort struct {
+	Port     int    `form:"port" validate:"required,min=1,max=65535"`
+	Protocol string `form:"protocol" validate:"omitempty,oneof=TCP UDP"`
+}
+
+type validateContainer struct {
+	validateBase
+
+	Image string         `form:"image" validate:"required"`
+	Ports []validatePort `form:"ports" validate:"dive"`
+}
+
+type validateConfig struct {
+	Hostname   string                       `form:"hostname" validate:"required"`
+	Containers map[string]validateContainer `form:"containers" validate:"dive"`
+
+	check error
+}
+
+func (conf *validateConfig) Validate() error {
+	return conf.check
+}
+
+func Test_U_Validate(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Config         any
+		ExpectedFields []*FieldError
+		ExpectedErr    error
+	}{
+		"valid": {
+			Config: &validateConfig{
+				Hostname: "ctfer.io",
+				Containers: map[string]validateContainer{
+					"app": {
+						Image: "pandatix/license-lvl1:latest",
+						Ports: []validatePort{{Port: 8080, Protocol: "TCP"}},
+					},
+				},
+			},
+		},
+		"missing-required": {
+			Config: &validateConfig{},
+			ExpectedFields: []*FieldError{
+				{Path: "hostname", Rule: "required"},
+			},
+		},
+		"nested-paths": {
+			Config: &validateConfig{
+				Hostname: "ctfer.io",
+				Containers: map[string]validateContainer{
+					"app": {
+						validateBase: validateBase{RunAsUser: -1},
+						Ports:        []validatePort{{Port: 70000, Protocol: "SCTP"}},
+					},
+				},
+			},
+			ExpectedFields: []*FieldError{
+				{Path: "containers[app].runAsUser", Rule: "gte=0"},
+				{Path: "containers[app].image", Rule: "required"},
+				{Path: "containers[app].ports[0].port", Rule: "max=65535"},
+				{Path: "containers[app].ports[0].protocol", Rule: "oneof=TCP UDP"},
+			},
+		},
+		"validator": {
+			Config: &validateConfig{
+				Hostname: "ctfer.io",
+				check:    errors.New("cross-field check failed"),
+			},
+			ExpectedErr: errors.New("cross-field check failed"),
+		},
+		"validator-and-tags": {
+			Config: &validateConfig{
+				check: errors.New("cross-field check failed"),
+			},
+			ExpectedFields: []*FieldError{
+				{Path: "hostname", Rule: "required"},
+			},
+			ExpectedErr: errors.New("cross-field check failed"),
+		},
+		"not-a-struct": {
+			Config: &map[string]string{"hostname": ""},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			err := Validate(tt.Config)
+			if len(tt.ExpectedFields) == 0 && tt.ExpectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+
+			fields := []*FieldError{}
+			var others []error
+			for _, err := range multierr.Errors(err) {
+				var ferr *FieldError
+				if errors.As(err, &ferr) {
+					fields = append(fields, ferr)
+					continue
+				}
+				others = append(others, err)
+			}
+			assert.ElementsMatch(t, tt.ExpectedFields, fields)
+			if tt.ExpectedErr != nil {
+				require.Len(t, others, 1)
+				assert.Equal(t, tt.ExpectedErr.Error(), others[0].Error())
+			} else {
+				assert.Empty(t, others)
+			}
+		})
+	}
+}
+
+func Test_U_FieldError(t *testing.T) {
+	t.Parallel()
+
+	err := &FieldError{Path: "containers[app].ports[0].port", Rule: "max=65535"}
+	assert.Equal(t, "containers[app].ports[0].port: max=65535", err.Error())
+}